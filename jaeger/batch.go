@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"golang.org/x/crypto/openpgp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type renderJob struct {
+	inputTemplate string
+	jsonGPGDB     string
+	outputFile    string
+}
+
+type renderSummary struct {
+	Written []string
+	Skipped []string
+	Failed  []string
+}
+
+func (s renderSummary) String() string {
+	return fmt.Sprintf("Summary: %d written, %d skipped, %d failed", len(s.Written), len(s.Skipped), len(s.Failed))
+}
+
+func findRenderJobs(renderDir *string) (jobs []renderJob, skipped []string, err error) {
+	// Walk the directory tree and pair each template with the JSON GPG database file of the same base name
+	err = filepath.Walk(*renderDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, jaegerTemplateExtension) {
+			return nil
+		}
+		basefilename := strings.TrimSuffix(path, jaegerTemplateExtension)
+		jsonGPGDB := fmt.Sprintf("%v%v", basefilename, jaegerDBExtension)
+		if _, err := os.Stat(jsonGPGDB); err != nil {
+			jaegerlog.Debug("no JSON GPG database file for template", jaegerlog.Template(path))
+			skipped = append(skipped, path)
+			return nil
+		}
+		jobs = append(jobs, renderJob{inputTemplate: path, jsonGPGDB: jsonGPGDB, outputFile: basefilename})
+		return nil
+	})
+	return jobs, skipped, err
+}
+
+func renderDirectory(renderDir *string, workers int, entitylist openpgp.EntityList) (renderSummary, error) {
+	var summary renderSummary
+
+	jobs, skipped, err := findRenderJobs(renderDir)
+	if err != nil {
+		return summary, err
+	}
+	summary.Skipped = skipped
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Decrypt and render with a bounded pool of workers. The private key was decrypted once up front and is
+	// shared read-only between them.
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan renderJob)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				err := renderJobFile(job, entitylist)
+				mu.Lock()
+				if err != nil {
+					jaegerlog.Error(err.Error(), jaegerlog.Operation("render"), jaegerlog.Template(job.inputTemplate), jaegerlog.Store(job.jsonGPGDB))
+					summary.Failed = append(summary.Failed, job.inputTemplate)
+				} else {
+					fmt.Println("Wrote file:", job.outputFile)
+					summary.Written = append(summary.Written, job.outputFile)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	sort.Strings(summary.Written)
+	sort.Strings(summary.Failed)
+
+	for _, path := range summary.Skipped {
+		fmt.Println("Skipped (no JSON GPG database file):", path)
+	}
+
+	return summary, nil
+}
+
+func renderJobFile(job renderJob, entitylist openpgp.EntityList) error {
+	p, err := parseJaegerDBFile(&job.jsonGPGDB, entitylist)
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(&job.inputTemplate, &job.outputFile, p)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testEntityList(t *testing.T) openpgp.EntityList {
+	// A small, throwaway key pair. Test keys are kept short so generating them is fast.
+	t.Helper()
+	entity, err := openpgp.NewEntity("Jaeger Test", "", "jaeger@example.com", &packet.Config{RSABits: 1024, DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return openpgp.EntityList{entity}
+}
+
+func writeTestStore(t *testing.T, path string, values map[string]string, entitylist openpgp.EntityList) {
+	t.Helper()
+	var j jaegerstore.Data
+	for name, value := range values {
+		encrypted, err := jaegerstore.Encrypt(value, entitylist)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.Properties = append(j.Properties, jaegerstore.Property{Name: name, EncryptedValue: encrypted})
+	}
+	content, err := jaegerstore.Marshal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindRenderJobs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.conf.jgrt"), "")
+	writeTestFile(t, filepath.Join(dir, "a.conf.jgrdb"), "")
+	writeTestFile(t, filepath.Join(dir, "sub", "b.yaml.jgrt"), "")
+	writeTestFile(t, filepath.Join(dir, "sub", "b.yaml.jgrdb"), "")
+	writeTestFile(t, filepath.Join(dir, "sub", "no-store.jgrt"), "")
+	writeTestFile(t, filepath.Join(dir, "other.txt"), "")
+
+	jobs, skipped, err := findRenderJobs(&dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJobs := []renderJob{
+		{filepath.Join(dir, "a.conf.jgrt"), filepath.Join(dir, "a.conf.jgrdb"), filepath.Join(dir, "a.conf")},
+		{filepath.Join(dir, "sub", "b.yaml.jgrt"), filepath.Join(dir, "sub", "b.yaml.jgrdb"), filepath.Join(dir, "sub", "b.yaml")},
+	}
+	if !reflect.DeepEqual(jobs, wantJobs) {
+		t.Errorf("findRenderJobs() jobs = %+v, want %+v", jobs, wantJobs)
+	}
+	if want := []string{filepath.Join(dir, "sub", "no-store.jgrt")}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("findRenderJobs() skipped = %q, want %q", skipped, want)
+	}
+}
+
+func TestRenderDirectory(t *testing.T) {
+	isolateUserDirs(t)
+	entitylist := testEntityList(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		base     string
+		template string
+		values   map[string]string
+		want     string // Content of the Output file, empty when rendering fails
+	}{
+		{"rendered", "a.conf", "password = {{.Password}}\n", map[string]string{"Password": "s3cret"}, "password = s3cret\n"},
+		{"rendered in a subdirectory", filepath.Join("sub", "b.conf"), "user = {{.User}}\n", map[string]string{"User": "admin"}, "user = admin\n"},
+		{"invalid template", "c.conf", "{{.Broken\n", map[string]string{}, ""},
+	}
+	for _, test := range tests {
+		base := filepath.Join(dir, test.base)
+		writeTestFile(t, base+jaegerTemplateExtension, test.template)
+		writeTestStore(t, base+jaegerDBExtension, test.values, entitylist)
+	}
+	writeTestFile(t, filepath.Join(dir, "d.conf.jgrt"), "no store\n")
+
+	summary, err := renderDirectory(&dir, 2, entitylist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Written) != 2 || len(summary.Failed) != 1 || len(summary.Skipped) != 1 {
+		t.Errorf("renderDirectory() = %v, want 2 written, 1 skipped, 1 failed", summary)
+	}
+	for _, test := range tests {
+		content, err := ioutil.ReadFile(filepath.Join(dir, test.base))
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: Output file written: %q", test.name, content)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(content) != test.want {
+			t.Errorf("%s: Output file %q, want %q", test.name, content, test.want)
+		}
+	}
+}
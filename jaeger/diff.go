@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const diffContextLines = 3
+const maskedValue = "********"
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+func diffOutputFile(inputTemplate *string, outputFile *string, p map[string]string, stored []string, printDiff bool, showSecrets bool) (bool, error) {
+	// Render to memory and compare against the file on disk. Returns true when the file is up to date. stored
+	// are the values of every version kept of the properties, which the file on disk may still hold.
+	rendered, err := renderTemplate(inputTemplate, p)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := ioutil.ReadFile(*outputFile)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if bytes.Equal(existing, rendered) {
+		jaegerlog.Debug("output file is up to date", jaegerlog.Output(*outputFile))
+		return true, nil
+	}
+
+	if printDiff {
+		var lines []diffLine
+		if showSecrets {
+			lines = diffLines(splitLines(string(existing)), splitLines(string(rendered)))
+		} else {
+			lines = maskedDiffLines(string(existing), string(rendered), p, stored)
+		}
+		fmt.Print(unifiedDiff(*outputFile, *outputFile+" (rendered)", lines))
+	}
+
+	return false, nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n\\ No newline at end of file"
+	}
+	return lines
+}
+
+func diffLines(a, b []string) []diffLine {
+	// Longest common subsequence line diff. Configuration files are small so the quadratic table is fine.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+func storedValues(jsonGPGDB *string, entitylist openpgp.EntityList) ([]string, error) {
+	// Decrypt every version kept of each property, the current one and the previous ones
+	jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Unable to read JSON GPG DB file: %v", *jsonGPGDB)
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+
+	var values []string
+	for _, property := range j.Properties {
+		versions := append([]jaegerstore.PropertyVersion{{Version: property.Version, EncryptedValue: property.EncryptedValue}}, property.History...)
+		for _, version := range versions {
+			value, err := jaegerstore.Decrypt(version.EncryptedValue, entitylist)
+			if err != nil {
+				return nil, fmt.Errorf("%v (version %d of property '%s' in %v)", err, version.Version, property.Name, *jsonGPGDB)
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+func maskValues(p map[string]string, stored []string) []string {
+	// The rendered and stored values, and the forms they take when rendered with json, toml or printf "%q",
+	// longest first so a value containing another value is masked whole
+	seen := make(map[string]bool)
+	var values []string
+	add := func(v string) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	all := append([]string(nil), stored...)
+	for _, v := range p {
+		all = append(all, v)
+	}
+	for _, v := range all {
+		add(v)
+		if quoted, err := jaegertemplate.JSONString(v); err == nil {
+			add(quoted[1 : len(quoted)-1])
+		}
+		quoted := jaegertemplate.TOMLString(v)
+		add(quoted[1 : len(quoted)-1])
+		quoted = strconv.Quote(v)
+		add(quoted[1 : len(quoted)-1])
+	}
+	sort.Strings(values)
+	sort.SliceStable(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+func maskText(s string, values []string) string {
+	// Replace each value with a mask, keeping the line breaks of a value that spans lines so the masked text has
+	// the same lines as s
+	for _, v := range values {
+		parts := strings.Split(v, "\n")
+		for i, part := range parts {
+			if part != "" {
+				parts[i] = maskedValue
+			}
+		}
+		s = strings.Replace(s, v, strings.Join(parts, "\n"), -1)
+	}
+	return s
+}
+
+func maskedDiffLines(existing string, rendered string, p map[string]string, stored []string) []diffLine {
+	// Diff the files line by line and show the lines of the masked files. Masking the whole files rather than each
+	// line catches values that span lines. A removed line may also hold an old value that is no longer kept, so a
+	// removed line paired with an added line holding a masked value has the differing part masked too.
+	values := maskValues(p, stored)
+	oldMasked := splitLines(maskText(existing, values))
+	newMasked := splitLines(maskText(rendered, values))
+	lines := diffLines(splitLines(existing), splitLines(rendered))
+
+	masked := make([]diffLine, len(lines))
+	o, n := 0, 0
+	for i, l := range lines {
+		switch l.op {
+		case ' ':
+			masked[i] = diffLine{' ', oldMasked[o]}
+			o++
+			n++
+		case '-':
+			masked[i] = diffLine{'-', oldMasked[o]}
+			o++
+		default:
+			masked[i] = diffLine{'+', newMasked[n]}
+			n++
+		}
+	}
+
+	for i := 0; i < len(masked); {
+		if masked[i].op == ' ' {
+			i++
+			continue
+		}
+		// A change block is a run of removed lines followed by a run of added lines
+		start := i
+		for i < len(masked) && masked[i].op == '-' {
+			i++
+		}
+		removed := i
+		for i < len(masked) && masked[i].op == '+' {
+			i++
+		}
+		for k := start; k < removed && removed+k-start < i; k++ {
+			added := masked[removed+k-start].text
+			if strings.Contains(added, maskedValue) {
+				masked[k].text = maskChangedSpan(masked[k].text, added, []string{maskedValue})
+			}
+		}
+	}
+	return masked
+}
+
+func maskChangedSpan(old, new string, values []string) string {
+	// Mask the part of old that differs from new, keeping the common prefix and suffix outside of any value
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	// Widen the span to whole values in new so a common tail of the old and new value is not left visible
+	for _, v := range values {
+		for from := 0; ; {
+			idx := strings.Index(new[from:], v)
+			if idx < 0 {
+				break
+			}
+			start, end := from+idx, from+idx+len(v)
+			if start <= len(new)-suffix && end >= prefix {
+				if start < prefix {
+					prefix = start
+				}
+				if len(new)-end < suffix {
+					suffix = len(new) - end
+				}
+			}
+			from = end
+		}
+	}
+	if prefix+suffix >= len(old) {
+		return old
+	}
+	return old[:prefix] + maskedValue + old[len(old)-suffix:]
+}
+
+func unifiedDiff(fromFile, toFile string, lines []diffLine) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromFile, toFile)
+
+	// Line numbers (1 based) of each diff line in the old and new files
+	oldLine := make([]int, len(lines)+1)
+	newLine := make([]int, len(lines)+1)
+	o, n := 1, 1
+	for i, l := range lines {
+		oldLine[i], newLine[i] = o, n
+		if l.op != '+' {
+			o++
+		}
+		if l.op != '-' {
+			n++
+		}
+	}
+	oldLine[len(lines)], newLine[len(lines)] = o, n
+
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are within twice the context of each other
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for k := i; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContextLines {
+				break
+			}
+		}
+		stop := end + diffContextLines
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		oldCount, newCount := 0, 0
+		for _, l := range lines[start:stop] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+		for _, l := range lines[start:stop] {
+			fmt.Fprintf(&buf, "%c%s\n", l.op, l.text)
+		}
+		i = stop
+	}
+
+	return buf.String()
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Signals received by jaeger that are passed on to the child process
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+func execWithProperties(args []string, p map[string]string) (int, error) {
+	// Run the command with the properties added to the environment, so the decrypted values never touch the
+	// filesystem. Returns the exit status of the command.
+	start := time.Now()
+
+	env := os.Environ()
+	for _, k := range sortedKeys(p) {
+		if !shellIdentifier.MatchString(k) {
+			return 0, fmt.Errorf("ERROR: '%s' is not a valid environment variable name. Try -key-transform %v", k, keyTransformUpperSnake)
+		}
+		env = append(env, k+"="+p[k])
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Start listening before the child exists so no signal is missed in between
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("ERROR: Unable to run command: %v", err)
+	}
+	jaegerlog.Debug("started command", jaegerlog.Operation("exec"), "command", args[0], "pid", cmd.Process.Pid, "properties", len(p))
+
+	go func() {
+		for sig := range signals {
+			jaegerlog.Debug("forwarding signal", "signal", sig.String(), "pid", cmd.Process.Pid)
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	status := exitStatus(cmd.ProcessState)
+	jaegerlog.Info("command exited", jaegerlog.Operation("exec"), "command", args[0], "status", status, jaegerlog.Duration(start))
+
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return 0, err
+	}
+	return status, nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+)
+
+func exitStatus(state *os.ProcessState) int {
+	return state.ExitCode()
+}
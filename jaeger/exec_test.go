@@ -0,0 +1,40 @@
+//go:build unix
+
+package main
+
+import (
+	"testing"
+)
+
+func TestExecWithProperties(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		properties map[string]string
+		want       int
+		wantErr    bool
+	}{
+		{"success", []string{"true"}, nil, 0, false},
+		{"exit status", []string{"sh", "-c", "exit 3"}, nil, 3, false},
+		{"properties in the environment", []string{"sh", "-c", `test "$DB_PASSWORD" = "it's \$x"`}, map[string]string{"DB_PASSWORD": "it's $x"}, 0, false},
+		{"killed by a signal", []string{"sh", "-c", "kill -KILL $$"}, nil, 128 + 9, false},
+		{"invalid variable name", []string{"true"}, map[string]string{"db.password": "x"}, 0, true},
+		{"missing command", []string{"/nonexistent/jaeger-test"}, nil, 0, true},
+	}
+	for _, test := range tests {
+		got, err := execWithProperties(test.args, test.properties)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got status %d", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	forwardedSignals = append(forwardedSignals, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH)
+	reloadSignals["USR1"] = syscall.SIGUSR1
+	reloadSignals["USR2"] = syscall.SIGUSR2
+}
+
+func exitStatus(state *os.ProcessState) int {
+	// Follow the shell convention of 128 + signal number for a child killed by a signal
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Key transformations applied to property names when exporting
+const (
+	keyTransformNone       = "none"
+	keyTransformUpperSnake = "upper-snake"
+	keyTransformLowerSnake = "lower-snake"
+)
+
+var shellIdentifier = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+func transformKey(name string, transform string, prefix string) (string, error) {
+	switch transform {
+	case "", keyTransformNone:
+	case keyTransformUpperSnake:
+		name = strings.ToUpper(snakeCase(name))
+	case keyTransformLowerSnake:
+		name = strings.ToLower(snakeCase(name))
+	default:
+		return "", fmt.Errorf("ERROR: Unknown key transform: %v. Use %v, %v or %v", transform, keyTransformNone, keyTransformUpperSnake, keyTransformLowerSnake)
+	}
+	return prefix + name, nil
+}
+
+func snakeCase(name string) string {
+	// Split into words on non-alphanumerics and case changes, eg. DatabasePassword and HTTPServer2URL
+	// become Database_Password and HTTP_Server2_URL
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return strings.Join(words, "_")
+}
+
+func transformProperties(p map[string]string, transform string, prefix string) (map[string]string, error) {
+	// Transform every property name, refusing to silently merge two properties into one name
+	transformed := make(map[string]string, len(p))
+	from := make(map[string]string, len(p))
+	for name, value := range p {
+		key, err := transformKey(name, transform, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if other, found := from[key]; found {
+			return nil, fmt.Errorf("ERROR: Properties '%s' and '%s' both export as '%s'", other, name, key)
+		}
+		from[key] = name
+		transformed[key] = value
+	}
+	return transformed, nil
+}
+
+func sortedKeys(p map[string]string) []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func exportProperties(p map[string]string, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "env":
+		for _, k := range sortedKeys(p) {
+			if !shellIdentifier.MatchString(k) {
+				return nil, fmt.Errorf("ERROR: '%s' is not a valid environment variable name. Try -key-transform %v", k, keyTransformUpperSnake)
+			}
+			fmt.Fprintf(&buf, "export %s=%s\n", k, shellQuote(p[k]))
+		}
+	case "dotenv":
+		for _, k := range sortedKeys(p) {
+			if !shellIdentifier.MatchString(k) {
+				return nil, fmt.Errorf("ERROR: '%s' is not a valid environment variable name. Try -key-transform %v", k, keyTransformUpperSnake)
+			}
+			value, err := dotenvQuote(p[k])
+			if err != nil {
+				return nil, fmt.Errorf("ERROR: Property '%s': %v. Use -export json", k, err)
+			}
+			fmt.Fprintf(&buf, "%s=%s\n", k, value)
+		}
+	case "json":
+		b, err := json.MarshalIndent(p, "", "    ")
+		if err != nil {
+			return nil, fmt.Errorf("error: %v", err)
+		}
+		buf.Write(b)
+		buf.WriteString("\n")
+	default:
+		return nil, fmt.Errorf("ERROR: Unknown export format: %v. Use env, dotenv or json", format)
+	}
+	return buf.Bytes(), nil
+}
+
+func dotenvQuote(s string) (string, error) {
+	// Dotenv loaders take single quoted values literally, so use single quotes unless the value holds a single
+	// quote or a line break. Otherwise use double quotes, escaped as jaegerdb -import reads them back.
+	if !strings.ContainsAny(s, "'\n\r") {
+		if _, err := jaegertemplate.DotenvString(s); err != nil {
+			return "", err
+		}
+		return "'" + s + "'", nil
+	}
+	return jaegertemplate.DotenvString(s)
+}
+
+func shellQuote(s string) string {
+	// Single quotes preserve everything literally except a single quote, which is closed, escaped and reopened
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
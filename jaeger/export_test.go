@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDotenvQuote(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", `'plain'`, false},
+		{"", `''`, false},
+		{"x$y `cmd` \\n", "'x$y `cmd` \\n'", false},
+		{"tab\there", "'tab\there'", false},
+		{"it's", `"it's"`, false},
+		{"it's $HOME", `"it's \$HOME"`, false},
+		{"it's `cmd` \\ \"q\"", "\"it's \\`cmd\\` \\\\ \\\"q\\\"\"", false},
+		{"line\nbreak", `"line\nbreak"`, false},
+		{"cr\r", `"cr\r"`, false},
+		{"unicode é  ", "'unicode é  '", false},
+		{"nul\x00", "", true},
+		{"escape\x1b", "", true},
+	}
+	for _, test := range tests {
+		got, err := dotenvQuote(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("dotenvQuote(%q): expected an error, got %s", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("dotenvQuote(%q): unexpected error: %v", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("dotenvQuote(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestExportPropertiesDotenv(t *testing.T) {
+	got, err := exportProperties(map[string]string{"B": "x$y", "A": "it's"}, "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "A=\"it's\"\nB='x$y'\n"
+	if string(got) != want {
+		t.Errorf("exportProperties() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportDirData is the symlink to the current set of files. Each exported file is a symlink through it, so
+// replacing this one link switches every file at once. This is the layout Kubernetes uses for volume mounts.
+const exportDirData = "..data"
+
+type exportDirSummary struct {
+	Dir     string
+	Written int
+	Removed []string
+}
+
+func (s exportDirSummary) String() string {
+	return fmt.Sprintf("Wrote %d files to directory: %v, removed %d stale files", s.Written, s.Dir, len(s.Removed))
+}
+
+func exportDirectory(dir string, p map[string]string) (exportDirSummary, error) {
+	start := time.Now()
+	summary := exportDirSummary{Dir: dir}
+
+	for name := range p {
+		if !kubernetesKey.MatchString(name) || strings.HasPrefix(name, "..") || name == "." {
+			return summary, fmt.Errorf("ERROR: '%s' is not a valid file name", name)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+
+	// Write every file into a new timestamped directory
+	dataDir, err := ioutil.TempDir(dir, ".."+time.Now().UTC().Format("2006_01_02_15_04_05."))
+	if err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+	for name, value := range p {
+		if err := ioutil.WriteFile(filepath.Join(dataDir, name), []byte(value), 0400); err != nil {
+			os.RemoveAll(dataDir)
+			return summary, fmt.Errorf("error: %v", err)
+		}
+	}
+	if err := os.Chmod(dataDir, 0755); err != nil {
+		os.RemoveAll(dataDir)
+		return summary, fmt.Errorf("error: %v", err)
+	}
+
+	// Atomically point the data link at the new directory
+	dataLink := filepath.Join(dir, exportDirData)
+	oldDataDir, _ := os.Readlink(dataLink)
+	if err := replaceSymlink(filepath.Base(dataDir), dataLink); err != nil {
+		os.RemoveAll(dataDir)
+		return summary, err
+	}
+	jaegerlog.Debug("switched data directory", "dir", dir, "data", filepath.Base(dataDir))
+
+	// Make sure each property has a link through the data link. Links that already exist need no change.
+	for name := range p {
+		path := filepath.Join(dir, name)
+		target := filepath.Join(exportDirData, name)
+		if current, err := os.Readlink(path); err == nil && current == target {
+			summary.Written++
+			continue
+		}
+		if err := replaceSymlink(target, path); err != nil {
+			return summary, err
+		}
+		summary.Written++
+	}
+
+	// Remove links for properties that are no longer in the JSON GPG database. Other files are left alone.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, found := p[name]; found || strings.HasPrefix(name, "..") {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil || target != filepath.Join(exportDirData, name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return summary, fmt.Errorf("error: %v", err)
+		}
+		jaegerlog.Debug("removed stale file", "dir", dir, jaegerlog.Property(name))
+		summary.Removed = append(summary.Removed, name)
+	}
+
+	if oldDataDir != "" && oldDataDir != filepath.Base(dataDir) && !strings.Contains(oldDataDir, string(filepath.Separator)) {
+		os.RemoveAll(filepath.Join(dir, oldDataDir))
+	}
+
+	jaegerlog.Info("exported properties to directory", jaegerlog.Operation("export-dir"), "dir", dir,
+		"written", summary.Written, "removed", len(summary.Removed), jaegerlog.Duration(start))
+	return summary, nil
+}
+
+func replaceSymlink(target string, path string) error {
+	// Create the link beside path and rename it over path, which is atomic
+	tmp := fmt.Sprintf("%s.tmp%d", path, os.Getpid())
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error: %v", err)
+	}
+	return nil
+}
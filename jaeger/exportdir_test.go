@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExportDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "unrelated"), "kept")
+
+	if _, err := exportDirectory(dir, map[string]string{"A": "1", "B": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := exportDirectory(dir, map[string]string{"A": "changed", "C": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Written != 2 || !reflect.DeepEqual(summary.Removed, []string{"B"}) {
+		t.Errorf("exportDirectory() = %+v, want 2 written and B removed", summary)
+	}
+
+	want := map[string]string{"A": "changed", "C": "3", "unrelated": "kept"}
+	for name, value := range want {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(content) != value {
+			t.Errorf("%s = %q, want %q", name, content, value)
+		}
+	}
+
+	// Only the data link, the current data directory and the exported files are left
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, dataDirs []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") && entry.Name() != exportDirData {
+			dataDirs = append(dataDirs, entry.Name())
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if wantNames := []string{exportDirData, "A", "C", "unrelated"}; !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("directory holds %q, want %q", names, wantNames)
+	}
+	if len(dataDirs) != 1 {
+		t.Errorf("directory holds data directories %q, want one", dataDirs)
+	}
+}
+
+func TestExportDirectoryInvalidNames(t *testing.T) {
+	for _, name := range []string{"..data", ".", "a/b", "a b"} {
+		dir := t.TempDir()
+		if _, err := exportDirectory(dir, map[string]string{name: "x"}); err == nil {
+			t.Errorf("exportDirectory(%q): expected an error", name)
+		}
+	}
+}
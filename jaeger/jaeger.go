@@ -2,17 +2,23 @@ package main
 
 import (
 	"bytes"
-	"golang.org/x/crypto/openpgp"
-	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"golang.org/x/crypto/openpgp"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
-	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 )
 
 const jaegerTemplateExtension = ".jgrt"
@@ -21,36 +27,45 @@ const jaegerDescription = "Jaeger - Template injection program\n\nJaeger is a JS
 const jaegerQuote = "\"Stacker Pentecost: Haven't you heard Mr. Beckett? The world is coming to an end. So where would you rather die? Here? Or in a Jaeger!\" - Pacific Rim"
 const jaegerRecommendedUsage = "RECOMMENDED:\n    jaeger -i file.txt.jgrt\n\nThis will run Jaeger with the default options and assume the following:\n    JSON GPG database file: file.txt.jgrdb\n    Output file: file.txt\n    Keyring file: ~/.gnupg/jaeger_secring.gpg\n    No passphrase"
 
-var debug debugging = false
-
-type debugging bool
-
-func (d debugging) Printf(format string, args ...interface{}) {
-	// From: https://groups.google.com/forum/#!msg/golang-nuts/gU7oQGoCkmg/BNIl-TqB-4wJ
-	if d {
-		log.Printf(format, args...)
-	}
-}
-
-type Data struct {
-	Properties []Property
-}
-
-type Property struct {
-	Name           string //`json:"Name"`
-	EncryptedValue string //`json:"EncryptedValue"`
-}
-
 func main() {
 	// Define flags
+	logFlags := jaegerlog.RegisterFlags()
 	var (
-		debugFlag         = flag.Bool("d", false, "Enable Debug")
 		inputTemplate     = flag.String("i", "", "Input Template file. eg. file.txt.jgrt")
 		jsonGPGDB         = flag.String("j", "", "JSON GPG database file. eg. file.txt.jgrdb")
 		outputFile        = flag.String("o", "", "Output file. eg. file.txt")
 		keyringFile       = flag.String("k", "", "Keyring file. Secret key in ASCII armored format. eg. secret.asc")
 		passphraseKeyring = flag.String("p", "", "Passphrase for keyring. If this is not set the passphrase will be blank or read from the environment variable PASSPHRASE.")
+		renderDir         = flag.String("r", "", "Render every Template file found under this directory. eg. ./config")
+		workers           = flag.Int("workers", runtime.NumCPU(), "Number of JSON GPG database files decrypted concurrently when rendering a directory")
+		diffFlag          = flag.Bool("diff", false, "Print a unified diff between the existing Output file and the rendered Template without writing it")
+		checkFlag         = flag.Bool("check", false, "Exit with a non-zero status if the Output file is out of date. Nothing is written")
+		showSecrets       = flag.Bool("show-secrets", false, "Show decrypted values in -diff output instead of masking them")
+		exportFormat      = flag.String("export", "", "Export decrypted properties without a Template. One of: env, dotenv, json. Written to stdout unless -o is set")
+		keyTransform      = flag.String("key-transform", keyTransformNone, "Transform property names when exporting, with -exec or -k8s. One of: none, upper-snake, lower-snake. eg. DatabasePassword becomes DATABASE_PASSWORD with upper-snake")
+		keyPrefix         = flag.String("prefix", "", "Prefix added to property names when exporting, with -exec or -k8s. eg. APP_")
+		execFlag          = flag.Bool("exec", false, "Run the command given after -- with the decrypted properties in its environment. Nothing is written to disk")
+		kubernetesKind    = flag.String("k8s", "", "Write a Kubernetes manifest to stdout instead of the Output file. One of: secret, configmap. With -i the rendered Template is a single key, otherwise every property is a key")
+		kubernetesName    = flag.String("k8s-name", "", "Kubernetes object name. Defaults to the Template or JSON GPG database file name")
+		kubernetesNS      = flag.String("k8s-namespace", "", "Kubernetes namespace")
+		kubernetesString  = flag.Bool("k8s-string-data", false, "Use stringData instead of base64 encoded data in a Secret")
+		kubernetesLabels  = labelFlags{}
+		watchFlag         = flag.Bool("watch", false, "Keep running and render the Output file again whenever the Template or JSON GPG database file changes")
+		watchInterval     = flag.Duration("watch-interval", 2*time.Second, "How often to check for changes with -watch when native file watching is not available")
+		reloadPID         = flag.Int("reload-pid", 0, "With -watch, signal this process after the Output file changes")
+		reloadPIDFile     = flag.String("reload-pidfile", "", "With -watch, signal the process in this PID file after the Output file changes. eg. /run/nginx.pid")
+		reloadSignal      = flag.String("reload-signal", "HUP", "Signal sent by -reload-pid and -reload-pidfile. One of: HUP, INT, QUIT, TERM, USR1, USR2")
+		reloadCommand     = flag.String("reload-command", "", "With -watch, run this shell command after the Output file changes. eg. \"systemctl reload nginx\"")
+		validateCommand   = flag.String("validate", "", "Shell command that must succeed on the rendered output before the Output file is replaced. {{.Path}} is the rendered temp file. eg. \"nginx -t -c {{.Path}}\"")
+		validateFormat    = flag.Bool("validate-format", false, "Check the syntax of .json, .yaml, .toml and .ini Output files before they are replaced. The YAML, TOML and INI checks are structural, not full parsers")
+		lintFlag          = flag.Bool("lint", false, "Check that the properties used by the Template, or every Template under -r, match the property names in the JSON GPG database file. Nothing is decrypted. Exits with a non-zero status on problems")
+		scanFlag          = flag.Bool("scan", false, "Check the files and directories given as arguments, default the current directory, for decrypted values, eg. as a pre-commit hook. Directories are searched for Output files next to their Template. Exits with a non-zero status on leaks")
+		scanStagedFlag    = flag.Bool("staged", false, "With -scan, check the files staged in the git index as they will be committed, rather than the working tree. Used by the pre-commit hook")
+		installScan       = flag.Bool("install-scan-hook", false, "Install a pre-commit hook running -scan in the current git repository and start remembering keyed hashes of the values written to Output files, so -scan works without the secret key")
+		exportDir         = flag.String("export-dir", "", "Write each decrypted property to its own read only file in this directory, eg. /run/secrets. Files for properties no longer in the JSON GPG database are removed")
 	)
+	flag.Var(kubernetesLabels, "k8s-label", "Kubernetes label in the form key=value. May be repeated")
+	flag.Var(pinnedVersions, "pin", "Render an earlier version of a property, in the form property=version, eg. for an emergency rollback. See jaegerdb -history. May be repeated")
 
 	flag.Usage = func() {
 		fmt.Printf("%s\n%s\n\n%s\n\n", jaegerDescription, jaegerQuote, jaegerRecommendedUsage)
@@ -60,8 +75,200 @@ func main() {
 
 	flag.Parse()
 
-	if *debugFlag {
-		debug = true
+	if err := logFlags.Setup(); err != nil {
+		flag.Usage()
+		log.Fatalf("\n\n%s", err)
+	}
+
+	if *passphraseKeyring == "" {
+		passphrase := os.Getenv("PASSPHRASE")
+		if len(passphrase) != 0 {
+			*passphraseKeyring = passphrase
+		}
+	}
+
+	validation = outputValidation{Command: *validateCommand, Builtin: *validateFormat}
+
+	// Modes that export properties without rendering a Template
+	loadExportProperties := func() map[string]string {
+		if err := resolveJaegerDB(inputTemplate, jsonGPGDB); err != nil {
+			flag.Usage()
+			log.Fatalf("\n\n%s", err)
+		}
+		entitylist := loadPrivateKeyRing(keyringFile, passphraseKeyring)
+		p, err := parseJaegerDBFile(jsonGPGDB, entitylist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p, err = transformProperties(p, *keyTransform, *keyPrefix)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return p
+	}
+
+	if *lintFlag {
+		var summary lintSummary
+		if *renderDir != "" {
+			var err error
+			if summary, err = lintDirectory(renderDir); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			if *inputTemplate == "" {
+				flag.Usage()
+				log.Fatalf("\n\nError: No input template file specified")
+			}
+			if err := resolveJaegerDB(inputTemplate, jsonGPGDB); err != nil {
+				flag.Usage()
+				log.Fatalf("\n\n%s", err)
+			}
+			problems, err := lintTemplate(*inputTemplate, *jsonGPGDB)
+			if err != nil {
+				log.Fatal(err)
+			}
+			summary = lintSummary{Files: 1, Problems: problems}
+		}
+		for _, problem := range summary.Problems {
+			fmt.Println(problem)
+		}
+		fmt.Println(summary)
+		if len(summary.Problems) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *installScan {
+		hook, err := installScanHook()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Installed pre-commit hook:", hook)
+		os.Exit(0)
+	}
+
+	if *scanFlag {
+		paths := flag.Args()
+		if len(paths) == 0 {
+			paths = []string{"."}
+		}
+		// Without -k, values are matched by the hashes remembered when they were last written to an Output file
+		var entitylist openpgp.EntityList
+		if *keyringFile != "" {
+			entitylist = loadPrivateKeyRing(keyringFile, passphraseKeyring)
+		}
+		var summary scanSummary
+		var err error
+		if *scanStagedFlag {
+			if len(flag.Args()) > 0 {
+				flag.Usage()
+				log.Fatalf("\n\nError: -staged scans the staged files and takes no file arguments")
+			}
+			summary, err = scanStaged(entitylist)
+		} else {
+			summary, err = scanPaths(paths, entitylist)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, leak := range summary.Leaks {
+			fmt.Println(leak)
+		}
+		fmt.Println(summary)
+		if len(summary.Leaks) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *renderDir != "" {
+		if len(pinnedVersions) > 0 {
+			flag.Usage()
+			log.Fatalf("\n\nError: -pin cannot be used with -r, property names are not unique across JSON GPG database files")
+		}
+		// Only rendering and -lint go over a directory, the other modes work on one Template or JSON GPG database
+		for _, option := range []struct {
+			name string
+			set  bool
+		}{
+			{"-diff", *diffFlag},
+			{"-check", *checkFlag},
+			{"-exec", *execFlag},
+			{"-k8s", *kubernetesKind != ""},
+			{"-export", *exportFormat != ""},
+			{"-export-dir", *exportDir != ""},
+			{"-watch", *watchFlag},
+		} {
+			if option.set {
+				flag.Usage()
+				log.Fatalf("\n\nError: %s cannot be used with -r", option.name)
+			}
+		}
+		entitylist := loadPrivateKeyRing(keyringFile, passphraseKeyring)
+		summary, err := renderDirectory(renderDir, *workers, entitylist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(summary)
+		if len(summary.Failed) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *execFlag {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			log.Fatalf("\n\nError: No command specified. eg. jaeger -j file.jgrdb -exec -- ./myserver --flag")
+		}
+		p := loadExportProperties()
+		status, err := execWithProperties(flag.Args(), p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(status)
+	}
+
+	if *kubernetesKind != "" && *inputTemplate == "" {
+		p := loadExportProperties()
+		if *kubernetesName == "" {
+			*kubernetesName = kubernetesObjectName(*jsonGPGDB)
+		}
+		manifest, err := newKubernetesManifest(*kubernetesKind, *kubernetesName, *kubernetesNS, kubernetesLabels, *kubernetesString, p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(manifest.YAML())
+		os.Exit(0)
+	}
+
+	if *exportDir != "" {
+		p := loadExportProperties()
+		summary, err := exportDirectory(*exportDir, p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(summary)
+		os.Exit(0)
+	}
+
+	if *exportFormat != "" {
+		p := loadExportProperties()
+		bytes, err := exportProperties(p, *exportFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *outputFile == "" {
+			os.Stdout.Write(bytes)
+			os.Exit(0)
+		}
+		if err := ioutil.WriteFile(*outputFile, bytes, 0600); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Wrote file:", *outputFile)
+		os.Exit(0)
 	}
 
 	if *inputTemplate == "" {
@@ -93,171 +300,212 @@ func main() {
 		*outputFile = basefilename
 	}
 
-	if *passphraseKeyring == "" {
-		passphrase := os.Getenv("PASSPHRASE")
-		if len(passphrase) != 0 {
-			*passphraseKeyring = passphrase
-		}
-	}
-
-	debug.Printf("basefilename:", basefilename)
-	debug.Printf("jsonGPGDB:", *jsonGPGDB)
-	debug.Printf("outputFile:", *outputFile)
-	debug.Printf("passphrase:", *passphraseKeyring)
-	debug.Printf("keyringFile:", *keyringFile)
-
-	// Read armored private key or default keyring into type EntityList
-	// An EntityList contains one or more Entities.
-	// This assumes there is only one Entity involved
-	// TODO: Support to prompt for passphrase
-
-	var entity *openpgp.Entity
-	var entitylist openpgp.EntityList
+	jaegerlog.Debug("options", "basefilename", basefilename, jaegerlog.Store(*jsonGPGDB), jaegerlog.Output(*outputFile),
+		jaegerlog.Passphrase("passphrase", *passphraseKeyring), "keyring", *keyringFile)
 
-	if *keyringFile == "" {
-		entity, entitylist = processSecretKeyRing()
-	} else {
-		entity, entitylist = processArmoredKeyRingFile(keyringFile)
-	}
+	entitylist := loadPrivateKeyRing(keyringFile, passphraseKeyring)
 
-	entity = decryptPrivateKeyRing(passphraseKeyring, entity)
-
-	p := make(map[string]string)
 	p, err := parseJaegerDBFile(jsonGPGDB, entitylist)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := writeOutputFile(inputTemplate, outputFile, p); err == nil {
-		fmt.Println("Wrote file:", *outputFile)
-	}
-
-}
-
-func decodeBase64EncryptedMessage(s string, keyring openpgp.KeyRing) string {
-	// Decrypt base64 encoded encrypted message using decrypted private key
-	dec, err := base64.StdEncoding.DecodeString(s)
-	if err != nil {
-		log.Fatalln("ERR:", err)
-	}
-	debug.Printf("keyring: #%v", keyring)
-	md, err := openpgp.ReadMessage(bytes.NewBuffer(dec), keyring, nil, nil)
-	if err != nil {
-		log.Fatalln("ERR: Error reading message - ", err)
+	if *diffFlag || *checkFlag {
+		var stored []string
+		if *diffFlag && !*showSecrets {
+			if stored, err = storedValues(jsonGPGDB, entitylist); err != nil {
+				log.Fatal(err)
+			}
+		}
+		upToDate, err := diffOutputFile(inputTemplate, outputFile, p, stored, *diffFlag, *showSecrets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *checkFlag && !upToDate {
+			fmt.Fprintln(os.Stderr, "Output file is out of date:", *outputFile)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	bytes, err := ioutil.ReadAll(md.UnverifiedBody)
-	debug.Printf("md:", string(bytes))
-	return string(bytes)
-}
-
-func processSecretKeyRing() (entity *openpgp.Entity, entitylist openpgp.EntityList) {
-	// Get default secret keyring location
-	usr, err := user.Current()
-	if err != nil {
-		log.Fatal(err)
+	if *kubernetesKind != "" {
+		if *kubernetesName == "" {
+			*kubernetesName = kubernetesObjectName(*inputTemplate)
+		}
+		rendered, err := renderTemplate(inputTemplate, p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data := map[string]string{filepath.Base(*outputFile): string(rendered)}
+		manifest, err := newKubernetesManifest(*kubernetesKind, *kubernetesName, *kubernetesNS, kubernetesLabels, *kubernetesString, data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(manifest.YAML())
+		os.Exit(0)
 	}
 
-	jaegerSecretKeyRing := fmt.Sprintf("%v/.gnupg/jaeger_secring.gpg", usr.HomeDir)
-	secretKeyRing := ""
-
-	if _, err := os.Stat(jaegerSecretKeyRing); err == nil {
-		secretKeyRing = jaegerSecretKeyRing
-	} else {
-		secretKeyRing = fmt.Sprintf("%v/.gnupg/secring.gpg", usr.HomeDir)
+	if *watchFlag {
+		reload, err := newReloadAction(*reloadSignal, *reloadPID, *reloadPIDFile, *reloadCommand)
+		if err != nil {
+			flag.Usage()
+			log.Fatalf("\n\n%s", err)
+		}
+		log.Fatal(watchAndRender(inputTemplate, jsonGPGDB, outputFile, entitylist, reload, *watchInterval))
 	}
 
-	debug.Printf("secretKeyRing file:", secretKeyRing)
-	secretKeyRingBuffer, err := os.Open(secretKeyRing)
-	if err != nil {
-		panic(err)
-	}
-	entitylist, err = openpgp.ReadKeyRing(secretKeyRingBuffer)
-	if err != nil {
+	if err := writeOutputFile(inputTemplate, outputFile, p); err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println("Wrote file:", *outputFile)
 
-	entity = entitylist[0]
-	debug.Printf("Private key default keyring:", entity.Identities)
-
-	return entity, entitylist
 }
 
-func processArmoredKeyRingFile(keyringFile *string) (entity *openpgp.Entity, entitylist openpgp.EntityList) {
-	keyringFileBuffer, err := os.Open(*keyringFile)
-	if err != nil {
-		log.Fatalln("ERROR: Unable to read keyring file")
+func resolveJaegerDB(inputTemplate *string, jsonGPGDB *string) error {
+	// Modes that don't render a Template still accept -i to find the JSON GPG database file
+	if *jsonGPGDB != "" {
+		return nil
 	}
-	entitylist, err = openpgp.ReadArmoredKeyRing(keyringFileBuffer)
-	if err != nil {
-		log.Fatal(err)
+	if !strings.HasSuffix(*inputTemplate, jaegerTemplateExtension) {
+		return fmt.Errorf("ERROR: No JSON GPG DB file specified")
 	}
-	entity = entitylist[0]
-	debug.Printf("Private key from armored string:", entity.Identities)
-
-	return entity, entitylist
+	*jsonGPGDB = fmt.Sprintf("%v%v", strings.TrimSuffix(*inputTemplate, jaegerTemplateExtension), jaegerDBExtension)
+	return nil
 }
 
-func decryptPrivateKeyRing(passphraseKeyring *string, entity *openpgp.Entity) *openpgp.Entity {
-	// Decrypt private key using passphrase
-	passphrase := []byte(*passphraseKeyring)
-	if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
-		debug.Printf("Decrypting private key using passphrase")
-		err := entity.PrivateKey.Decrypt(passphrase)
-		if err != nil {
-			log.Fatalln("ERROR: Failed to decrypt key using passphrase. Make sure you specify a passphrase if required.")
-		}
-	}
-	for _, subkey := range entity.Subkeys {
-		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
-			err := subkey.PrivateKey.Decrypt(passphrase)
-			if err != nil {
-				log.Fatalln("ERROR: Failed to decrypt subkey")
-			}
-		}
+func loadPrivateKeyRing(keyringFile *string, passphraseKeyring *string) openpgp.EntityList {
+	// Read armored private key or default keyring into type EntityList and decrypt it
+	// TODO: Support to prompt for passphrase
+	entitylist, err := jaegerstore.SecretKeyRing(*keyringFile, *passphraseKeyring)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return entity
+	return entitylist
 }
 
 func parseJaegerDBFile(jsonGPGDB *string, entitylist openpgp.EntityList) (map[string]string, error) {
+	start := time.Now()
+
 	// json handling
 	jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB)
 	if err != nil {
-		return nil, fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+		return nil, fmt.Errorf("ERROR: Unable to read JSON GPG DB file: %v", *jsonGPGDB)
 	}
 
-	var j Data
+	var j jaegerstore.Data
 	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
-		return nil, fmt.Errorf("error:", err)
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	jaegerlog.Debug("json unmarshal", jaegerlog.Store(*jsonGPGDB), "properties", len(j.Properties))
+
+	if err := checkPins(j, *jsonGPGDB); err != nil {
+		return nil, err
 	}
-	debug.Printf("json unmarshal:", j)
 
 	p := make(map[string]string)
 
 	for _, v := range j.Properties {
-		debug.Printf("Name: %#v, EncryptedValue: %#v\n", v.Name, v.EncryptedValue)
-		p[v.Name] = decodeBase64EncryptedMessage(v.EncryptedValue, entitylist)
+		jaegerlog.Debug("decrypting property", jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(v.Name))
+		encryptedValue, err := pinnedValue(v, *jsonGPGDB)
+		if err != nil {
+			return nil, err
+		}
+		value, err := jaegerstore.Decrypt(encryptedValue, entitylist)
+		if err != nil {
+			return nil, fmt.Errorf("%v (property '%s' in %v)", err, v.Name, *jsonGPGDB)
+		}
+		p[v.Name] = value
 	}
 
-	debug.Printf("properties map:", p)
+	jaegerlog.Info("decrypted store", jaegerlog.Operation("decrypt"), jaegerlog.Store(*jsonGPGDB), "properties", len(p), jaegerlog.Duration(start))
+	jaegerlog.Debug("properties map", jaegerlog.Store(*jsonGPGDB), "properties", jaegerlog.RedactProperties(p))
 	return p, nil
 }
 
-func writeOutputFile(inputTemplate *string, outputFile *string, p map[string]string) error {
+func renderTemplate(inputTemplate *string, p map[string]string) ([]byte, error) {
 	// Template parsing
-	t := template.Must(template.ParseFiles(*inputTemplate))
+	t, err := template.New(filepath.Base(*inputTemplate)).Funcs(jaegertemplate.FuncMap).ParseFiles(*inputTemplate)
+	if err != nil {
+		return nil, err
+	}
 
 	buf := new(bytes.Buffer)
-	t.Execute(buf, p) //merge template ‘t’ with content of ‘p’
+	if err := t.Execute(buf, p); err != nil { //merge template ‘t’ with content of ‘p’
+		return nil, err
+	}
 
 	bytes, _ := ioutil.ReadAll(buf)
-	debug.Printf(string(bytes))
+	jaegerlog.Debug("rendered template", jaegerlog.Template(*inputTemplate), jaegerlog.Secret("rendered", string(bytes)))
+
+	return bytes, nil
+}
+
+func writeOutputFile(inputTemplate *string, outputFile *string, p map[string]string) error {
+	start := time.Now()
+
+	bytes, err := renderTemplate(inputTemplate, p)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file beside the Output file and validate it there, then rename it into place so a failed
+	// validation or an interrupted write never replaces a working file
+	tmp, err := createTempFile(filepath.Dir(*outputFile), "."+filepath.Base(*outputFile)+".jaeger-", filepath.Ext(*outputFile), 0644)
+	if err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	defer os.Remove(tmp.Name())
 
-	// Writing file
 	// To handle large files, use a file buffer: http://stackoverflow.com/a/9739903/603745
-	if err := ioutil.WriteFile(*outputFile, bytes, 0644); err != nil {
-		return fmt.Errorf("error:", err)
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+
+	if err := validation.Validate(tmp.Name(), *outputFile); err != nil {
+		return fmt.Errorf("ERROR: Validation failed, not writing %v: %v", *outputFile, err)
+	}
+
+	// Keep the mode and owner of the file being replaced. A new file keeps the 0644 it was created with.
+	if info, err := os.Stat(*outputFile); err == nil {
+		if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("error: %v", err)
+		}
+		if err := copyOwner(tmp.Name(), info); err != nil {
+			jaegerlog.Warn("unable to keep the owner of the output file", jaegerlog.Output(*outputFile), "error", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error: %v", err)
 	}
+	if err := os.Rename(tmp.Name(), *outputFile); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+
+	jaegerlog.Info("wrote output file", jaegerlog.Operation("render"), jaegerlog.Template(*inputTemplate), jaegerlog.Output(*outputFile), jaegerlog.Duration(start))
 
+	// Remember the values written, for -scan
+	written := make(map[string]string)
+	for name, value := range p {
+		if strings.Contains(string(bytes), value) {
+			written[name] = value
+		}
+	}
+	rememberValues(written)
 	return nil
 }
+
+func createTempFile(dir string, prefix string, suffix string, perm os.FileMode) (*os.File, error) {
+	// Like ioutil.TempFile, but created with perm less the umask rather than 0600
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+	return nil, fmt.Errorf("unable to create a temp file in %v", dir)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var kubernetesKey = regexp.MustCompile("^[-._a-zA-Z0-9]+$")
+var kubernetesInvalidNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+type kubernetesManifest struct {
+	Kind       string // Secret or ConfigMap
+	Name       string
+	Namespace  string
+	Labels     map[string]string
+	StringData bool
+	Data       map[string]string
+}
+
+// labelFlags collects repeated -k8s-label key=value flags
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	var labels []string
+	for k, v := range l {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+func (l labelFlags) Set(value string) error {
+	s := strings.SplitN(value, "=", 2)
+	if len(s) != 2 || s[0] == "" {
+		return fmt.Errorf("label must be in the form key=value")
+	}
+	l[s[0]] = s[1]
+	return nil
+}
+
+func kubernetesObjectName(path string) string {
+	// Derive a valid object name from a file name, eg. app.conf.jgrdb becomes app-conf
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(strings.TrimSuffix(name, jaegerDBExtension), jaegerTemplateExtension)
+	name = kubernetesInvalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+func newKubernetesManifest(kind string, name string, namespace string, labels map[string]string, stringData bool, data map[string]string) (kubernetesManifest, error) {
+	switch strings.ToLower(kind) {
+	case "secret":
+		kind = "Secret"
+	case "configmap":
+		kind = "ConfigMap"
+	default:
+		return kubernetesManifest{}, fmt.Errorf("ERROR: Unknown Kubernetes kind: %v. Use secret or configmap", kind)
+	}
+	if name == "" {
+		return kubernetesManifest{}, fmt.Errorf("ERROR: No Kubernetes object name. Use -k8s-name")
+	}
+	for k := range data {
+		if !kubernetesKey.MatchString(k) {
+			return kubernetesManifest{}, fmt.Errorf("ERROR: '%s' is not a valid Kubernetes %s key", k, kind)
+		}
+	}
+	return kubernetesManifest{Kind: kind, Name: name, Namespace: namespace, Labels: labels, StringData: stringData, Data: data}, nil
+}
+
+func (m kubernetesManifest) YAML() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("apiVersion: v1\n")
+	fmt.Fprintf(&buf, "kind: %s\n", m.Kind)
+	buf.WriteString("metadata:\n")
+	fmt.Fprintf(&buf, "  name: %s\n", yamlQuote(m.Name))
+	if m.Namespace != "" {
+		fmt.Fprintf(&buf, "  namespace: %s\n", yamlQuote(m.Namespace))
+	}
+	if len(m.Labels) > 0 {
+		buf.WriteString("  labels:\n")
+		for _, k := range sortedKeys(m.Labels) {
+			fmt.Fprintf(&buf, "    %s: %s\n", yamlQuote(k), yamlQuote(m.Labels[k]))
+		}
+	}
+	if m.Kind == "Secret" {
+		buf.WriteString("type: Opaque\n")
+	}
+
+	if len(m.Data) == 0 {
+		return buf.Bytes()
+	}
+
+	// ConfigMap data and Secret stringData hold plain strings, Secret data holds base64
+	switch {
+	case m.Kind == "Secret" && !m.StringData:
+		buf.WriteString("data:\n")
+		for _, k := range sortedKeys(m.Data) {
+			fmt.Fprintf(&buf, "  %s: %s\n", yamlQuote(k), base64.StdEncoding.EncodeToString([]byte(m.Data[k])))
+		}
+	case m.Kind == "Secret":
+		buf.WriteString("stringData:\n")
+		for _, k := range sortedKeys(m.Data) {
+			fmt.Fprintf(&buf, "  %s: %s\n", yamlQuote(k), yamlQuote(m.Data[k]))
+		}
+	default:
+		buf.WriteString("data:\n")
+		for _, k := range sortedKeys(m.Data) {
+			fmt.Fprintf(&buf, "  %s: %s\n", yamlQuote(k), yamlQuote(m.Data[k]))
+		}
+	}
+	return buf.Bytes()
+}
+
+var yamlPlainScalar = regexp.MustCompile("^[A-Za-z_][-._A-Za-z0-9/]*$")
+
+func yamlQuote(s string) string {
+	// Plain scalars are used where YAML can't mistake them for another type, eg. true or null
+	if yamlPlainScalar.MatchString(s) {
+		switch strings.ToLower(s) {
+		case "true", "false", "yes", "no", "y", "n", "on", "off", "null":
+		default:
+			return s
+		}
+	}
+
+	// A JSON string is a valid YAML double quoted scalar
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestKubernetesObjectName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"app.conf.jgrdb", "app-conf"},
+		{"/etc/jaeger/My_App.yaml.jgrt", "my-app-yaml"},
+		{"_secrets_.jgrdb", "secrets"},
+	}
+	for _, test := range tests {
+		if got := kubernetesObjectName(test.path); got != test.want {
+			t.Errorf("kubernetesObjectName(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestNewKubernetesManifest(t *testing.T) {
+	tests := []struct {
+		kind     string
+		name     string
+		data     map[string]string
+		wantKind string
+		wantErr  bool
+	}{
+		{"secret", "app", map[string]string{"db.password": "x"}, "Secret", false},
+		{"ConfigMap", "app", map[string]string{"APP_MODE": "x"}, "ConfigMap", false},
+		{"deployment", "app", nil, "", true},
+		{"secret", "", nil, "", true},
+		{"secret", "app", map[string]string{"db password": "x"}, "", true},
+	}
+	for _, test := range tests {
+		got, err := newKubernetesManifest(test.kind, test.name, "", nil, false, test.data)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("newKubernetesManifest(%s, %q, %q): expected an error, got %+v", test.kind, test.name, test.data, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("newKubernetesManifest(%s, %q, %q): unexpected error: %v", test.kind, test.name, test.data, err)
+			continue
+		}
+		if got.Kind != test.wantKind {
+			t.Errorf("newKubernetesManifest(%s, %q, %q) kind = %s, want %s", test.kind, test.name, test.data, got.Kind, test.wantKind)
+		}
+	}
+}
+
+func TestKubernetesManifestYAML(t *testing.T) {
+	data := map[string]string{"password": "it's: true", "user": "admin"}
+	tests := []struct {
+		name     string
+		manifest kubernetesManifest
+		want     string
+	}{
+		{
+			"secret data is base64",
+			kubernetesManifest{Kind: "Secret", Name: "app", Namespace: "prod", Labels: map[string]string{"app": "web", "tier": "true"}, Data: data},
+			"apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\n  namespace: prod\n  labels:\n    app: web\n    tier: \"true\"\ntype: Opaque\n" +
+				"data:\n  password: aXQnczogdHJ1ZQ==\n  user: YWRtaW4=\n",
+		},
+		{
+			"secret stringData",
+			kubernetesManifest{Kind: "Secret", Name: "app", StringData: true, Data: data},
+			"apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\ntype: Opaque\nstringData:\n  password: \"it's: true\"\n  user: admin\n",
+		},
+		{
+			"configmap",
+			kubernetesManifest{Kind: "ConfigMap", Name: "app", Data: data},
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  password: \"it's: true\"\n  user: admin\n",
+		},
+		{
+			"no data",
+			kubernetesManifest{Kind: "Secret", Name: "app"},
+			"apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\ntype: Opaque\n",
+		},
+	}
+	for _, test := range tests {
+		if got := string(test.manifest.YAML()); got != test.want {
+			t.Errorf("%s: YAML() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestYAMLQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"admin", "admin"},
+		{"app/config-1.0", "app/config-1.0"},
+		{"Yes", `"Yes"`},
+		{"null", `"null"`},
+		{"123", `"123"`},
+		{"", `""`},
+		{"a: b # c", `"a: b # c"`},
+		{"<line>\nbreak", `"<line>\nbreak"`},
+	}
+	for _, test := range tests {
+		if got := yamlQuote(test.value); got != test.want {
+			t.Errorf("yamlQuote(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
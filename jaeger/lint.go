@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// lintProblem is a property referenced by a Template but missing from its JSON GPG database file, or the reverse
+type lintProblem struct {
+	Location string // file:line or file:line:col
+	Message  string
+}
+
+func (p lintProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Location, p.Message)
+}
+
+type lintSummary struct {
+	Files    int
+	Problems []lintProblem
+}
+
+func (s lintSummary) String() string {
+	return fmt.Sprintf("Summary: %d templates checked, %d problems", s.Files, len(s.Problems))
+}
+
+// propertyReference is a use of a property in a Template
+type propertyReference struct {
+	Name     string
+	Location string
+}
+
+func lintTemplate(inputTemplate string, jsonGPGDB string) ([]lintProblem, error) {
+	// Compare the properties a Template uses with the property names in its JSON GPG database file. Nothing is
+	// decrypted so no key is needed.
+	references, err := templateReferences(inputTemplate)
+	if err != nil {
+		return nil, err
+	}
+	names, lines, err := jaegerDBNames(jsonGPGDB)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []lintProblem
+	defined := make(map[string]int)
+	for i, name := range names {
+		location := fmt.Sprintf("%s:%d", jsonGPGDB, lines[i])
+		if first, found := defined[name]; found {
+			problems = append(problems, lintProblem{location, fmt.Sprintf("duplicate property %s, first defined on line %d", name, lines[first])})
+			continue
+		}
+		defined[name] = i
+	}
+
+	used := make(map[string]bool)
+	for _, reference := range references {
+		used[reference.Name] = true
+		if _, found := defined[reference.Name]; !found {
+			problems = append(problems, lintProblem{reference.Location, fmt.Sprintf("missing property %s, not in %s", reference.Name, jsonGPGDB)})
+		}
+	}
+
+	for i, name := range names {
+		if !used[name] && defined[name] == i {
+			problems = append(problems, lintProblem{fmt.Sprintf("%s:%d", jsonGPGDB, lines[i]), fmt.Sprintf("unused property %s, not referenced by %s", name, inputTemplate)})
+		}
+	}
+	return problems, nil
+}
+
+func lintDirectory(renderDir *string) (lintSummary, error) {
+	var summary lintSummary
+
+	jobs, skipped, err := findRenderJobs(renderDir)
+	if err != nil {
+		return summary, err
+	}
+	for _, path := range skipped {
+		summary.Problems = append(summary.Problems, lintProblem{path, "no JSON GPG database file for template"})
+	}
+	for _, job := range jobs {
+		problems, err := lintTemplate(job.inputTemplate, job.jsonGPGDB)
+		if err != nil {
+			summary.Problems = append(summary.Problems, lintProblem{job.inputTemplate, err.Error()})
+		}
+		summary.Problems = append(summary.Problems, problems...)
+		summary.Files++
+	}
+	return summary, nil
+}
+
+func templateReferences(inputTemplate string) ([]propertyReference, error) {
+	t, err := template.New(filepath.Base(inputTemplate)).Funcs(jaegertemplate.FuncMap).ParseFiles(inputTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates are visited by name, so problems are reported in the same order every run
+	templates := t.Templates()
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name() < templates[j].Name() })
+	var references []propertyReference
+	for _, tmpl := range templates {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+		tree := tmpl.Tree
+		add := func(name string, node parse.Node) {
+			location, _ := tree.ErrorContext(node)
+			// Locations are relative to the template name, the base name of the file
+			if strings.HasPrefix(location, tree.ParseName+":") {
+				location = inputTemplate + strings.TrimPrefix(location, tree.ParseName)
+			}
+			references = append(references, propertyReference{Name: name, Location: location})
+		}
+		jaegertemplate.WalkReferences(tree.Root, add)
+	}
+	return references, nil
+}
+
+var jaegerDBName = regexp.MustCompile(`"Name"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+
+func jaegerDBNames(jsonGPGDB string) ([]string, []int, error) {
+	// Property names in file order with the line each is on
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ERROR: Unable to read JSON GPG DB file: %v", err)
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return nil, nil, fmt.Errorf("ERROR: %v: %v", jsonGPGDB, err)
+	}
+
+	names := make([]string, len(j.Properties))
+	lines := make([]int, len(j.Properties))
+	matches := jaegerDBName.FindAllStringSubmatchIndex(string(jsonGPGDBBuffer), -1)
+	for i, property := range j.Properties {
+		names[i] = property.Name
+		lines[i] = 1
+		if len(matches) == len(j.Properties) {
+			lines[i] = strings.Count(string(jsonGPGDBBuffer[:matches[i][0]]), "\n") + 1
+		}
+	}
+	return names, lines, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const lintTestStore = `{
+  "Properties": [
+    {"Name": "Password", "EncryptedValue": "x"},
+    {"Name": "Unused", "EncryptedValue": "x"},
+    {"Name": "db.user", "EncryptedValue": "x"},
+    {"Name": "Password", "EncryptedValue": "x"}
+  ]
+}
+`
+
+func TestLintTemplate(t *testing.T) {
+	dir := t.TempDir()
+	inputTemplate := filepath.Join(dir, "app.conf.jgrt")
+	jsonGPGDB := filepath.Join(dir, "app.conf.jgrdb")
+	writeTestFile(t, inputTemplate, "password = {{json .Password}}\n"+
+		"user = {{index . \"db.user\"}}\n"+
+		"{{if .Debug}}debug = true{{end}}\n"+
+		"{{define \"extra\"}}{{.Missing}}{{end}}\n")
+	writeTestFile(t, jsonGPGDB, lintTestStore)
+
+	problems, err := lintTemplate(inputTemplate, jsonGPGDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, problem := range problems {
+		got = append(got, problem.String())
+	}
+	want := []string{
+		jsonGPGDB + ":6: duplicate property Password, first defined on line 3",
+		inputTemplate + ":3:5: missing property Debug, not in " + jsonGPGDB,
+		inputTemplate + ":4:20: missing property Missing, not in " + jsonGPGDB,
+		jsonGPGDB + ":4: unused property Unused, not referenced by " + inputTemplate,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lintTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestLintTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "valid.jgrt"), "{{.A}}\n")
+	writeTestFile(t, filepath.Join(dir, "invalid.jgrt"), "{{.A\n")
+	writeTestFile(t, filepath.Join(dir, "valid.jgrdb"), `{"Properties": [{"Name": "A", "EncryptedValue": "x"}]}`)
+	writeTestFile(t, filepath.Join(dir, "invalid.jgrdb"), "{")
+
+	tests := []struct {
+		name          string
+		inputTemplate string
+		jsonGPGDB     string
+	}{
+		{"invalid template", "invalid.jgrt", "valid.jgrdb"},
+		{"invalid store", "valid.jgrt", "invalid.jgrdb"},
+		{"missing store", "valid.jgrt", "missing.jgrdb"},
+	}
+	for _, test := range tests {
+		if _, err := lintTemplate(filepath.Join(dir, test.inputTemplate), filepath.Join(dir, test.jsonGPGDB)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestLintDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.conf.jgrt"), "{{.Password}}\n")
+	writeTestFile(t, filepath.Join(dir, "a.conf.jgrdb"), `{"Properties": [{"Name": "Password", "EncryptedValue": "x"}]}`)
+	writeTestFile(t, filepath.Join(dir, "sub", "b.conf.jgrt"), "{{.Broken\n")
+	writeTestFile(t, filepath.Join(dir, "sub", "b.conf.jgrdb"), `{"Properties": []}`)
+	writeTestFile(t, filepath.Join(dir, "c.conf.jgrt"), "{{.Password}}\n")
+
+	summary, err := lintDirectory(&dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Files != 2 || len(summary.Problems) != 2 {
+		t.Fatalf("lintDirectory() = %v %q, want 2 templates checked and 2 problems", summary, summary.Problems)
+	}
+	if want := (lintProblem{filepath.Join(dir, "c.conf.jgrt"), "no JSON GPG database file for template"}); summary.Problems[0] != want {
+		t.Errorf("lintDirectory() problem %v, want %v", summary.Problems[0], want)
+	}
+	if summary.Problems[1].Location != filepath.Join(dir, "sub", "b.conf.jgrt") {
+		t.Errorf("lintDirectory() problem %v, want a parse error for b.conf.jgrt", summary.Problems[1])
+	}
+}
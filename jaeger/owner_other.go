@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+)
+
+func copyOwner(path string, info os.FileInfo) error {
+	return nil
+}
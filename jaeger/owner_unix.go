@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func copyOwner(path string, info os.FileInfo) error {
+	// Give path the owner and group of the file described by info. Only root can give a file away, so this is
+	// only attempted when the owner differs.
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if s, ok := current.Sys().(*syscall.Stat_t); ok && s.Uid == stat.Uid && s.Gid == stat.Gid {
+		return nil
+	}
+	return os.Chown(path, int(stat.Uid), int(stat.Gid))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pinFlags collects repeated -pin property=version flags
+type pinFlags map[string]int
+
+func (f pinFlags) String() string {
+	var pins []string
+	for name, version := range f {
+		pins = append(pins, fmt.Sprintf("%s=%d", name, version))
+	}
+	sort.Strings(pins)
+	return strings.Join(pins, ",")
+}
+
+func (f pinFlags) Set(value string) error {
+	s := strings.SplitN(value, "=", 2)
+	if len(s) != 2 || s[0] == "" {
+		return fmt.Errorf("pin must be in the form property=version")
+	}
+	version, err := strconv.Atoi(s[1])
+	if err != nil || version < 1 {
+		return fmt.Errorf("pin version must be a positive number, see jaegerdb -history")
+	}
+	f[s[0]] = version
+	return nil
+}
+
+// Property versions to render instead of the current values, set with -pin
+var pinnedVersions = pinFlags{}
+
+func checkPins(j jaegerstore.Data, jsonGPGDB string) error {
+	// A pin for a property that isn't in the database is most likely a typo, which would render the current value
+	var missing []string
+	for name := range pinnedVersions {
+		if j.Find(name) == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("ERROR: Pinned property '%s' not found in %v", strings.Join(missing, "', '"), jsonGPGDB)
+	}
+	return nil
+}
+
+func pinnedValue(property jaegerstore.Property, jsonGPGDB string) (string, error) {
+	// The encrypted value to render: the current value unless the property is pinned to an earlier version
+	version, pinned := pinnedVersions[property.Name]
+	if !pinned || version == property.Version || (version == 1 && property.Version == 0) {
+		return property.EncryptedValue, nil
+	}
+	for _, previous := range property.History {
+		if previous.Version == version {
+			jaegerlog.Warn("rendering pinned version", jaegerlog.Store(jsonGPGDB), jaegerlog.Property(property.Name), "version", version)
+			return previous.EncryptedValue, nil
+		}
+	}
+	return "", fmt.Errorf("ERROR: Version %d of property '%s' not found in %v. Use jaegerdb -history to list the versions kept.", version, property.Name, jsonGPGDB)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/jyap808/jaeger/jaegerstore"
+	"testing"
+)
+
+func setTestPins(t *testing.T, pins pinFlags) {
+	// pinnedVersions is set by the -pin flag, so restore it after the test
+	t.Helper()
+	saved := pinnedVersions
+	pinnedVersions = pins
+	t.Cleanup(func() { pinnedVersions = saved })
+}
+
+func TestPinFlagsSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"A=2", false},
+		{"db.password=1", false},
+		{"A", true},
+		{"=2", true},
+		{"A=0", true},
+		{"A=x", true},
+	}
+	for _, test := range tests {
+		err := pinFlags{}.Set(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Set(%q) error = %v, want error %v", test.value, err, test.wantErr)
+		}
+	}
+}
+
+func TestPinnedValue(t *testing.T) {
+	property := jaegerstore.Property{Name: "A"}
+	property.SetValue("v1", 10)
+	property.SetValue("v2", 10)
+	property.SetValue("v3", 10)
+	j := jaegerstore.Data{Properties: []jaegerstore.Property{property, {Name: "B", EncryptedValue: "b"}}}
+
+	tests := []struct {
+		name    string
+		pins    pinFlags
+		want    string
+		wantErr bool
+	}{
+		{"not pinned", pinFlags{}, "v3", false},
+		{"pinned to the current version", pinFlags{"A": 3}, "v3", false},
+		{"pinned to an earlier version", pinFlags{"A": 1}, "v1", false},
+		{"pinned to a missing version", pinFlags{"A": 7}, "", true},
+		{"other property pinned", pinFlags{"B": 1}, "v3", false},
+	}
+	for _, test := range tests {
+		setTestPins(t, test.pins)
+		if err := checkPins(j, "test.jgrdb"); err != nil {
+			t.Errorf("%s: checkPins() unexpected error: %v", test.name, err)
+		}
+		got, err := pinnedValue(property, "test.jgrdb")
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: pinnedValue() = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	setTestPins(t, pinFlags{"A": 1, "Missing": 2})
+	if err := checkPins(j, "test.jgrdb"); err == nil {
+		t.Errorf("checkPins() with a pin for a missing property: expected an error")
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Shorter values, such as ports or flags, would match all over
+const scanMinLength = 4
+
+// Longer values are not remembered, so scanning a line stays fast
+const scanMaxLength = 4096
+
+// Files larger than this are not rendered configuration files
+const scanMaxSize = 1 << 20
+
+// The pre-commit hook written by -install-scan-hook
+const scanHook = "#!/bin/sh\n# Installed by jaeger -install-scan-hook\nexec jaeger -scan -staged\n"
+
+// scanCache holds HMACs of the values written to Output files, so -scan can find leaked values without the
+// secret key. The HMAC key is kept in the user config directory, away from the cache, and the value lengths
+// needed to scan a line are stored as HMACs too. Nothing is remembered until -install-scan-hook creates the key.
+type scanCache struct {
+	key    []byte
+	hashes map[string]bool
+}
+
+// scanCacheFile is the cache as written to the user cache directory
+type scanCacheFile struct {
+	Hashes []string
+}
+
+var scanCacheMu sync.Mutex
+
+func scanKeyPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "jaeger", "scan.key"), nil
+}
+
+func scanCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "jaeger", "scan-hashes.json"), nil
+}
+
+func loadScanCache() (scanCache, string, error) {
+	// Without a key the cache is disabled and has no key
+	cache := scanCache{hashes: make(map[string]bool)}
+	keyPath, err := scanKeyPath()
+	if err != nil {
+		return cache, "", err
+	}
+	key, err := ioutil.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		return cache, "", nil
+	}
+	if err != nil {
+		return cache, "", err
+	}
+	if cache.key, err = hex.DecodeString(strings.TrimSpace(string(key))); err != nil || len(cache.key) < 16 {
+		return scanCache{hashes: make(map[string]bool)}, "", fmt.Errorf("%v: invalid scan key", keyPath)
+	}
+
+	path, err := scanCachePath()
+	if err != nil {
+		return cache, "", err
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, path, nil
+	}
+	if err != nil {
+		return cache, path, err
+	}
+	var file scanCacheFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return cache, path, fmt.Errorf("%v: %v", path, err)
+	}
+	for _, hash := range file.Hashes {
+		cache.hashes[hash] = true
+	}
+	return cache, path, nil
+}
+
+func (c scanCache) hash(value string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte("value\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c scanCache) lengthHash(length int) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(fmt.Sprintf("length\x00%d", length)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c scanCache) lengths() []int {
+	// The lengths of the remembered values, shortest first
+	var lengths []int
+	if c.key == nil || len(c.hashes) == 0 {
+		return nil
+	}
+	for length := scanMinLength; length <= scanMaxLength; length++ {
+		if c.hashes[c.lengthHash(length)] {
+			lengths = append(lengths, length)
+		}
+	}
+	return lengths
+}
+
+func rememberValues(p map[string]string) {
+	// Add the HMACs of values written to an Output file to the cache. The cache is a convenience so errors are
+	// only logged.
+	scanCacheMu.Lock()
+	defer scanCacheMu.Unlock()
+
+	cache, path, err := loadScanCache()
+	if err != nil {
+		jaegerlog.Debug("unable to read scan cache", "error", err)
+		return
+	}
+	if cache.key == nil {
+		return
+	}
+
+	added := 0
+	for _, value := range p {
+		if len(value) < scanMinLength || len(value) > scanMaxLength {
+			continue
+		}
+		for _, hash := range []string{cache.hash(value), cache.lengthHash(len(value))} {
+			if !cache.hashes[hash] {
+				cache.hashes[hash] = true
+				added++
+			}
+		}
+	}
+	if added == 0 {
+		return
+	}
+
+	var file scanCacheFile
+	for hash := range cache.hashes {
+		file.Hashes = append(file.Hashes, hash)
+	}
+	sort.Strings(file.Hashes)
+	content, err := json.Marshal(file)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		jaegerlog.Debug("unable to write scan cache", "error", err)
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".scan-hashes-*.json")
+	if err != nil {
+		jaegerlog.Debug("unable to write scan cache", "error", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		jaegerlog.Debug("unable to write scan cache", "error", err)
+		return
+	}
+	jaegerlog.Debug("updated scan cache", "path", path, "added", added)
+}
+
+func installScanHook() (string, error) {
+	// Write the pre-commit hook of the current git repository and create the scan key, so rendered values are
+	// remembered from now on
+	hooks, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("ERROR: Not in a git repository: %v", err)
+	}
+	hook := filepath.Join(strings.TrimSpace(string(hooks)), "pre-commit")
+	if content, err := ioutil.ReadFile(hook); err == nil && string(content) != scanHook {
+		return "", fmt.Errorf("ERROR: %v already exists. Add this line to it instead: %s", hook, strings.TrimSpace(strings.SplitN(scanHook, "\n", 3)[2]))
+	}
+	if err := os.MkdirAll(filepath.Dir(hook), 0755); err != nil {
+		return "", fmt.Errorf("error: %v", err)
+	}
+	if err := ioutil.WriteFile(hook, []byte(scanHook), 0755); err != nil {
+		return "", fmt.Errorf("error: %v", err)
+	}
+
+	keyPath, err := scanKeyPath()
+	if err != nil {
+		return "", fmt.Errorf("error: %v", err)
+	}
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return "", fmt.Errorf("error: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+			return "", fmt.Errorf("error: %v", err)
+		}
+		if err := ioutil.WriteFile(keyPath, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			return "", fmt.Errorf("error: %v", err)
+		}
+		jaegerlog.Info("created scan key", "path", keyPath)
+	}
+	return hook, nil
+}
+
+// scanTarget is a file to scan and, for a rendered Output file, the JSON GPG database file it was rendered from
+type scanTarget struct {
+	path      string
+	jsonGPGDB string
+	staged    bool // Scan the content staged in the git index rather than the working tree
+}
+
+type scanSummary struct {
+	Files int
+	Leaks []string // file:line: description
+}
+
+func (s scanSummary) String() string {
+	return fmt.Sprintf("Summary: %d files scanned, %d leaks", s.Files, len(s.Leaks))
+}
+
+func findScanTargets(paths []string) ([]scanTarget, error) {
+	// Files are scanned as given, eg. staged files from a pre-commit hook. Directories are walked for Output
+	// files next to their Template.
+	var targets []scanTarget
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			targets = append(targets, scanTarget{path: path, jsonGPGDB: pairedJaegerDB(path)})
+			continue
+		}
+		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if info.IsDir() || !strings.HasSuffix(path, jaegerTemplateExtension) {
+				return nil
+			}
+			output := strings.TrimSuffix(path, jaegerTemplateExtension)
+			if _, err := os.Stat(output); err == nil {
+				targets = append(targets, scanTarget{path: output, jsonGPGDB: pairedJaegerDB(output)})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+func stagedScanTargets() ([]scanTarget, error) {
+	// Files added, copied or modified in the git index, as a commit would record them. Paths are relative to the
+	// top of the working tree, where git runs hooks.
+	top, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Not in a git repository: %v", err)
+	}
+	cmd := exec.Command("git", "diff", "--cached", "--name-only", "--diff-filter=ACM", "-z")
+	cmd.Dir = strings.TrimSpace(string(top))
+	names, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Unable to list staged files: %v", err)
+	}
+	var targets []scanTarget
+	for _, name := range strings.Split(string(names), "\x00") {
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(cmd.Dir, filepath.FromSlash(name))
+		targets = append(targets, scanTarget{path: path, jsonGPGDB: pairedJaegerDB(path), staged: true})
+	}
+	return targets, nil
+}
+
+func readScanTarget(target scanTarget) ([]byte, bool, error) {
+	// The content of the file, and false if it is too large to scan
+	if !target.staged {
+		info, err := os.Stat(target.path)
+		if err != nil {
+			return nil, false, err
+		}
+		if info.Size() > scanMaxSize {
+			return nil, false, nil
+		}
+		content, err := ioutil.ReadFile(target.path)
+		return content, err == nil, err
+	}
+
+	cmd := exec.Command("git", "show", ":./"+filepath.Base(target.path))
+	cmd.Dir = filepath.Dir(target.path)
+	content, err := cmd.Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("ERROR: Unable to read staged file %v: %v", target.path, err)
+	}
+	return content, len(content) <= scanMaxSize, nil
+}
+
+func pairedJaegerDB(outputFile string) string {
+	jsonGPGDB := outputFile + jaegerDBExtension
+	if _, err := os.Stat(outputFile + jaegerTemplateExtension); err != nil {
+		return ""
+	}
+	if _, err := os.Stat(jsonGPGDB); err != nil {
+		return ""
+	}
+	return jsonGPGDB
+}
+
+func scanPaths(paths []string, entitylist openpgp.EntityList) (scanSummary, error) {
+	targets, err := findScanTargets(paths)
+	if err != nil {
+		return scanSummary{}, err
+	}
+	return scanTargets(targets, entitylist)
+}
+
+func scanStaged(entitylist openpgp.EntityList) (scanSummary, error) {
+	targets, err := stagedScanTargets()
+	if err != nil {
+		return scanSummary{}, err
+	}
+	return scanTargets(targets, entitylist)
+}
+
+func scanTargets(targets []scanTarget, entitylist openpgp.EntityList) (scanSummary, error) {
+	// Look for decrypted values in the files. With a secret key the paired JSON GPG database files are decrypted
+	// and leaks are named by property, otherwise the hashes cached by earlier renders are used.
+	var summary scanSummary
+
+	cache, _, err := loadScanCache()
+	if err != nil {
+		jaegerlog.Warn("unable to read scan cache", "error", err)
+	}
+	if cache.key == nil && entitylist == nil {
+		jaegerlog.Warn("no values remembered to scan for, use -install-scan-hook or -k")
+	}
+	lengths := cache.lengths()
+
+	decrypted := make(map[string]map[string]string)
+	for _, target := range targets {
+		var p map[string]string
+		if entitylist != nil && target.jsonGPGDB != "" {
+			if _, found := decrypted[target.jsonGPGDB]; !found {
+				jsonGPGDB := target.jsonGPGDB
+				values, err := parseJaegerDBFile(&jsonGPGDB, entitylist)
+				if err != nil {
+					return summary, err
+				}
+				decrypted[target.jsonGPGDB] = values
+			}
+			p = decrypted[target.jsonGPGDB]
+		}
+
+		content, read, err := readScanTarget(target)
+		if err != nil {
+			return summary, err
+		}
+		if !read || bytes.IndexByte(content, 0) >= 0 {
+			// Too large or a binary file
+			continue
+		}
+		leaks, err := scanFile(target.path, content, p, cache, lengths)
+		if err != nil {
+			return summary, err
+		}
+		summary.Files++
+		summary.Leaks = append(summary.Leaks, leaks...)
+	}
+	return summary, nil
+}
+
+func scanFile(path string, content []byte, p map[string]string, cache scanCache, lengths []int) ([]string, error) {
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var leaks []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), scanMaxSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+
+		found := false
+		for _, name := range names {
+			if len(p[name]) >= scanMinLength && strings.Contains(line, p[name]) {
+				leaks = append(leaks, fmt.Sprintf("%s:%d: contains the value of property %s", path, lineNumber, name))
+				found = true
+			}
+		}
+		if !found && scanLineHashes(line, cache, lengths) {
+			leaks = append(leaks, fmt.Sprintf("%s:%d: contains a decrypted value", path, lineNumber))
+		}
+	}
+	return leaks, scanner.Err()
+}
+
+func scanLineHashes(line string, cache scanCache, lengths []int) bool {
+	// Hash every substring with the length of a remembered value
+	for _, length := range lengths {
+		for i := 0; i+length <= len(line); i++ {
+			if cache.hashes[cache.hash(line[i:i+length])] {
+				return true
+			}
+		}
+	}
+	return false
+}
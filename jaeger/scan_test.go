@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func isolateUserDirs(t *testing.T) {
+	// Keep the scan key and cache of the tests out of the real user directories
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+}
+
+func writeScanKey(t *testing.T) {
+	t.Helper()
+	keyPath, err := scanKeyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, []byte(hex.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRememberValuesWithoutKey(t *testing.T) {
+	isolateUserDirs(t)
+	rememberValues(map[string]string{"A": "secret value"})
+	path, err := scanCachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("scan cache written without a scan key: %v", err)
+	}
+}
+
+func TestRememberValues(t *testing.T) {
+	isolateUserDirs(t)
+	writeScanKey(t)
+	rememberValues(map[string]string{"A": "secret value", "B": "abc", "C": "secret value"})
+
+	path, err := scanCachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"secret value", "Salt", ":12"} {
+		if strings.Contains(string(content), leaked) {
+			t.Errorf("scan cache holds %q: %s", leaked, content)
+		}
+	}
+
+	cache, _, err := loadScanCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One value hash and one length hash, values shorter than scanMinLength are left out
+	if len(cache.hashes) != 2 {
+		t.Errorf("scan cache holds %d hashes, want 2", len(cache.hashes))
+	}
+	if lengths := cache.lengths(); len(lengths) != 1 || lengths[0] != len("secret value") {
+		t.Errorf("lengths() = %v, want [%d]", lengths, len("secret value"))
+	}
+
+	// The hashes are keyed, another key does not match them
+	other := scanCache{key: []byte("another key of sixteen bytes"), hashes: cache.hashes}
+	if other.hashes[other.hash("secret value")] {
+		t.Errorf("hash matched with a different key")
+	}
+}
+
+func TestScanLineHashes(t *testing.T) {
+	cache := scanCache{key: []byte("0123456789abcdef"), hashes: make(map[string]bool)}
+	for _, value := range []string{"s3cret", "token-1234"} {
+		cache.hashes[cache.hash(value)] = true
+		cache.hashes[cache.lengthHash(len(value))] = true
+	}
+	lengths := cache.lengths()
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"password = s3cret", true},
+		{"s3cret", true},
+		{`{"a": "x", "token": "token-1234"}`, true},
+		{"password = s3cre", false},
+		{"password = S3CRET", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := scanLineHashes(test.line, cache, lengths); got != test.want {
+			t.Errorf("scanLineHashes(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestScanPaths(t *testing.T) {
+	isolateUserDirs(t)
+	writeScanKey(t)
+	dir := t.TempDir()
+	inputTemplate := filepath.Join(dir, "app.conf.jgrt")
+	outputFile := filepath.Join(dir, "app.conf")
+	if err := ioutil.WriteFile(inputTemplate, []byte("password = {{.Password}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Only values written to the Output file are remembered
+	if err := writeOutputFile(&inputTemplate, &outputFile, map[string]string{"Password": "s3cret", "Unused": "not written"}); err != nil {
+		t.Fatal(err)
+	}
+	leak := filepath.Join(dir, "notes.txt")
+	if err := ioutil.WriteFile(leak, []byte("first line\nthe password is s3cret\nnot written\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := scanPaths([]string{dir, leak}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{outputFile + ":1: contains a decrypted value", leak + ":2: contains a decrypted value"}
+	if summary.Files != 2 || strings.Join(summary.Leaks, "\n") != strings.Join(want, "\n") {
+		t.Errorf("scanPaths() = %d files, leaks %q, want 2 files, leaks %q", summary.Files, summary.Leaks, want)
+	}
+}
+
+func TestScanStaged(t *testing.T) {
+	isolateUserDirs(t)
+	writeScanKey(t)
+	rememberValues(map[string]string{"Password": "s3cret"})
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	git := func(args ...string) {
+		t.Helper()
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	git("init", "-q")
+
+	// The staged content is what gets committed, whatever the working tree holds
+	writeTestFile(t, filepath.Join(dir, "sub", "staged.txt"), "the password is s3cret\n")
+	writeTestFile(t, filepath.Join(dir, "unstaged.txt"), "nothing here\n")
+	git("add", ".")
+	writeTestFile(t, filepath.Join(dir, "sub", "staged.txt"), "the password is gone\n")
+	writeTestFile(t, filepath.Join(dir, "unstaged.txt"), "the password is s3cret\n")
+
+	summary, err := scanStaged(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "sub", "staged.txt") + ":1: contains a decrypted value"}
+	if summary.Files != 2 || strings.Join(summary.Leaks, "\n") != strings.Join(want, "\n") {
+		t.Errorf("scanStaged() = %d files, leaks %q, want 2 files, leaks %q", summary.Files, summary.Leaks, want)
+	}
+}
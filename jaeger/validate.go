@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// validation is set from the command line and applied by writeOutputFile before the Output file is replaced
+var validation outputValidation
+
+type outputValidation struct {
+	Command string // Shell command template. {{.Path}} is the rendered temp file
+	Builtin bool   // Check JSON, YAML, TOML and INI syntax by Output file extension
+}
+
+func (v outputValidation) Validate(path string, outputFile string) error {
+	if v.Builtin {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := validateFormat(filepath.Ext(outputFile), content); err != nil {
+			return err
+		}
+	}
+
+	if v.Command != "" {
+		t, err := template.New("validate").Parse(v.Command)
+		if err != nil {
+			return fmt.Errorf("ERROR: Invalid validate command: %v", err)
+		}
+		var command bytes.Buffer
+		if err := t.Execute(&command, struct{ Path string }{shellQuote(path)}); err != nil {
+			return fmt.Errorf("ERROR: Invalid validate command: %v", err)
+		}
+
+		jaegerlog.Debug("running validate command", jaegerlog.Operation("validate"), jaegerlog.Output(outputFile), "command", command.String())
+		cmd := exec.Command("/bin/sh", "-c", command.String())
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v\n%s", err, output)
+		}
+	}
+	return nil
+}
+
+func validateFormat(ext string, content []byte) error {
+	switch strings.ToLower(ext) {
+	case ".json":
+		return validateJSON(content)
+	case ".yaml", ".yml":
+		return validateYAML(content)
+	case ".toml":
+		return validateTOML(content)
+	case ".ini":
+		return validateINI(content)
+	}
+	return nil
+}
+
+func validateJSON(content []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line := bytes.Count(content[:syntaxErr.Offset], []byte("\n")) + 1
+			return fmt.Errorf("invalid JSON on line %d: %v", line, err)
+		}
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	return nil
+}
+
+var yamlMappingKey = regexp.MustCompile(`^(-\s+)?("[^"]*"|'[^']*'|[^\s#'"{\[][^:#]*?)\s*:(\s|$)`)
+
+func validateYAML(content []byte) error {
+	// A structural check rather than a full parser: indentation, quoted scalars and flow collections, which may
+	// span lines. Plain scalars are anything up to a comment, eg. 'title: a [b' is the string "a [b". It catches
+	// the common mistakes of a value injected into a template.
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	blockIndent := -1 // Indentation of the line that started a | or > block scalar
+	flowDepth := 0    // Nesting of an open flow sequence or mapping
+	flowLine := 0
+	var quote byte // Quote of an open quoted scalar
+	quoteLine := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if quote != 0 {
+			if end := yamlQuoteEnd(trimmed, quote); end >= 0 {
+				quote = 0
+			}
+			continue
+		}
+		if flowDepth > 0 {
+			flowDepth += bracketDepth(trimmed)
+			if flowDepth < 0 {
+				return fmt.Errorf("invalid YAML on line %d: unbalanced brackets", lineNumber)
+			}
+			continue
+		}
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return fmt.Errorf("invalid YAML on line %d: tabs are not allowed in indentation", lineNumber)
+		}
+
+		value := trimmed
+		if m := yamlMappingKey.FindStringSubmatch(trimmed); m != nil {
+			value = strings.TrimSpace(trimmed[len(m[0]):])
+		} else if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			value = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+		}
+		switch {
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			blockIndent = indent
+		case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+			end := yamlQuoteEnd(value[1:], value[0])
+			if end < 0 {
+				quote, quoteLine = value[0], lineNumber
+				continue
+			}
+			if rest := strings.TrimSpace(value[1+end+1:]); rest != "" && !strings.HasPrefix(rest, "#") && !strings.HasPrefix(rest, ":") {
+				return fmt.Errorf("invalid YAML on line %d: unexpected text after quoted string", lineNumber)
+			}
+		case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{"):
+			if flowDepth = bracketDepth(value); flowDepth < 0 {
+				return fmt.Errorf("invalid YAML on line %d: unbalanced brackets", lineNumber)
+			}
+			flowLine = lineNumber
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("invalid YAML on line %d: unterminated quoted string", quoteLine)
+	}
+	if flowDepth > 0 {
+		return fmt.Errorf("invalid YAML on line %d: unterminated flow collection", flowLine)
+	}
+	return scanner.Err()
+}
+
+func yamlQuoteEnd(s string, quote byte) int {
+	// Index of the closing quote in s, or -1. Double quoted scalars escape with \, single quoted with ''.
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+var tomlTable = regexp.MustCompile(`^\[\[?\s*[A-Za-z0-9_."' -]+\s*\]\]?\s*(#.*)?$`)
+var tomlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z0-9_-]+)(\s*\.\s*("[^"]*"|'[^']*'|[A-Za-z0-9_-]+))*\s*=`)
+var tomlBareValue = regexp.MustCompile(`^(true|false|[+-]?(inf|nan|0x[0-9a-fA-F_]+|0o[0-7_]+|0b[01_]+|[0-9_]+(\.[0-9_]+)?([eE][+-]?[0-9_]+)?)|` +
+	`\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)$`)
+
+func validateTOML(content []byte) error {
+	// A structural check: tables, key = value pairs with valid values, multi-line strings and arrays, and keys
+	// defined twice in the same table
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	table := ""
+	seen := make(map[string]int)
+	var multiline string // Closing delimiter of an open multi-line string
+	depth := 0           // Nesting of an open multi-line array or inline table
+	for scanner.Scan() {
+		lineNumber++
+		trimmed := strings.TrimSpace(scanner.Text())
+
+		if multiline != "" {
+			if strings.Contains(trimmed, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+		if depth > 0 {
+			depth += bracketDepth(trimmed)
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			if !tomlTable.MatchString(trimmed) {
+				return fmt.Errorf("invalid TOML on line %d: invalid table header", lineNumber)
+			}
+			table = strings.Trim(strings.SplitN(trimmed, "#", 2)[0], "[] \t")
+			if strings.HasPrefix(trimmed, "[[") {
+				// Each element of an array of tables has its own keys
+				table = fmt.Sprintf("%s[%d]", table, lineNumber)
+			}
+			continue
+		}
+
+		m := tomlKey.FindString(trimmed)
+		if m == "" {
+			return fmt.Errorf("invalid TOML on line %d: expected key = value", lineNumber)
+		}
+		key := table + "." + strings.Replace(strings.TrimSpace(strings.TrimSuffix(m, "=")), " ", "", -1)
+		if first, found := seen[key]; found {
+			return fmt.Errorf("invalid TOML on line %d: key already defined on line %d", lineNumber, first)
+		}
+		seen[key] = lineNumber
+
+		value := strings.TrimSpace(trimmed[len(m):])
+		switch {
+		case value == "":
+			return fmt.Errorf("invalid TOML on line %d: missing value", lineNumber)
+		case strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''"):
+			delimiter := value[:3]
+			if !strings.Contains(value[3:], delimiter) {
+				multiline = delimiter
+			}
+		case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{"):
+			if err := checkQuotesAndBrackets(value); err != nil && err != errUnclosedBracket {
+				return fmt.Errorf("invalid TOML on line %d: %v", lineNumber, err)
+			}
+			depth = bracketDepth(value)
+		case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+			if err := checkQuotesAndBrackets(value); err != nil {
+				return fmt.Errorf("invalid TOML on line %d: %v", lineNumber, err)
+			}
+			if rest := strings.TrimSpace(value[quotedEnd(value):]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return fmt.Errorf("invalid TOML on line %d: unexpected text after string", lineNumber)
+			}
+		default:
+			bare := strings.TrimSpace(strings.SplitN(value, "#", 2)[0])
+			if !tomlBareValue.MatchString(bare) {
+				return fmt.Errorf("invalid TOML on line %d: invalid value, strings must be quoted", lineNumber)
+			}
+		}
+	}
+	if multiline != "" {
+		return fmt.Errorf("invalid TOML: unterminated multi-line string")
+	}
+	if depth > 0 {
+		return fmt.Errorf("invalid TOML: unterminated array or inline table")
+	}
+	return scanner.Err()
+}
+
+var iniSection = regexp.MustCompile(`^\[[^\]]+\]\s*([;#].*)?$`)
+var iniKey = regexp.MustCompile(`^[^=:\[\s][^=:]*[=:]`)
+
+func validateINI(content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		trimmed := strings.TrimSpace(scanner.Text())
+		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			if !iniSection.MatchString(trimmed) {
+				return fmt.Errorf("invalid INI on line %d: invalid section header", lineNumber)
+			}
+			continue
+		}
+		if !iniKey.MatchString(trimmed) {
+			return fmt.Errorf("invalid INI on line %d: expected key = value", lineNumber)
+		}
+	}
+	return scanner.Err()
+}
+
+var errUnclosedBracket = fmt.Errorf("unclosed bracket")
+
+func checkQuotesAndBrackets(s string) error {
+	// Check quoted strings are terminated and brackets are balanced, ignoring a # comment and any quotes or
+	// brackets in it
+	var stack []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\'':
+			if c == '\'' && i > 0 && s[i-1] != ' ' && s[i-1] != '[' && s[i-1] != '{' && s[i-1] != ',' && s[i-1] != ':' {
+				// An apostrophe inside a plain word, eg. don't
+				continue
+			}
+			end := i + 1
+			for ; end < len(s); end++ {
+				if c == '"' && s[end] == '\\' {
+					end++
+					continue
+				}
+				if s[end] == c {
+					break
+				}
+			}
+			if end >= len(s) {
+				return fmt.Errorf("unterminated quoted string")
+			}
+			i = end
+		case '#':
+			i = len(s)
+		case '[', '{':
+			stack = append(stack, c)
+		case ']', '}':
+			open := byte('[')
+			if c == '}' {
+				open = '{'
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return fmt.Errorf("unbalanced '%c'", c)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		return errUnclosedBracket
+	}
+	return nil
+}
+
+func quotedEnd(s string) int {
+	// Index just past the quoted string at the start of s. Only double quoted strings have escapes.
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+		} else if s[i] == quote {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func bracketDepth(s string) int {
+	// Net brackets opened on a line of a multi-line array or inline table, outside quoted strings
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
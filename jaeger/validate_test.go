@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"object", `{"a": "b", "n": [1, 2]}`, false},
+		{"unquoted value", `{"a": b}`, true},
+		{"trailing comma", `{"a": "b",}`, true},
+		{"truncated", `{"a": "b"`, true},
+	}
+	for _, test := range tests {
+		err := validateJSON([]byte(test.content))
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"mapping", "a: 1\nb:\n  c: two words\n", false},
+		{"bracket inside a plain scalar", "title: a [b\n", false},
+		{"apostrophe inside a plain scalar", "title: don't\n", false},
+		{"multi-line flow sequence", "hosts: [\n  a,\n  b\n]\n", false},
+		{"multi-line flow mapping", "db: {\n  user: x,\n  password: \"y\"\n}\n", false},
+		{"flow sequence", "a: [1, [2, 3]]\n", false},
+		{"quoted scalars", "a: \"x # y\"\nb: 'it''s'\n", false},
+		{"multi-line quoted scalar", "a: \"first\n  second\"\nb: 1\n", false},
+		{"block scalar", "key: |\n  -----BEGIN KEY-----\n  a: [\nb: 1\n", false},
+		{"sequence of mappings", "- name: a\n  value: 'b'\n", false},
+		{"comment after quoted scalar", "a: \"x\" # comment\n", false},
+		{"unterminated double quote", "a: \"x\nb: 1\n", true},
+		{"unterminated single quote", "a: 'it's'\n", true},
+		{"text after quoted scalar", "a: \"x\" y\n", true},
+		{"unterminated flow sequence", "a: [1, 2\nb: 3\n", true},
+		{"unbalanced flow sequence", "a: [1]]\n", true},
+		{"tab indentation", "a:\n\tb: 1\n", true},
+	}
+	for _, test := range tests {
+		err := validateYAML([]byte(test.content))
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateTOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"tables and values", "title = \"x\"\n[db]\nport = 5432\nratio = 1.5\nenabled = true\n", false},
+		{"array of tables", "[[servers]]\nname = \"a\"\n[[servers]]\nname = \"b\"\n", false},
+		{"multi-line string", "key = \"\"\"\nline\n\"\"\"\n", false},
+		{"multi-line array", "hosts = [\n  \"a\",\n  \"b\",\n]\n", false},
+		{"dotted key", "a.b = 1\n", false},
+		{"date", "day = 2024-01-02\n", false},
+		{"unquoted string", "password = secret\n", true},
+		{"duplicate key", "a = 1\na = 2\n", true},
+		{"missing value", "a =\n", true},
+		{"invalid table header", "[db\n", true},
+		{"text after string", "a = \"x\" y\n", true},
+		{"unterminated multi-line string", "a = '''\nx\n", true},
+		{"quote in a comment", "a = \"x\" # say \"hi\n", false},
+		{"bracket in a comment", "b = [1, 2] # see ]\n", false},
+		{"brackets in a comment after an inline table", "c = {d = 1} # {[\n", false},
+	}
+	for _, test := range tests {
+		err := validateTOML([]byte(test.content))
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateINI(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"sections", "; comment\n[db]\nuser = x\npassword: y\n", false},
+		{"invalid section header", "[db\n", true},
+		{"line without a key", "just text\n", true},
+	}
+	for _, test := range tests {
+		err := validateINI([]byte(test.content))
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	tests := []struct {
+		ext     string
+		content string
+		wantErr bool
+	}{
+		{".json", "{", true},
+		{".JSON", "{", true},
+		{".yml", "a: [", true},
+		{".toml", "a = b", true},
+		{".ini", "text", true},
+		// Many .cfg files, eg. haproxy.cfg, are not INI files
+		{".cfg", "global\n    maxconn 256\n", false},
+		{".conf", "anything {", false},
+	}
+	for _, test := range tests {
+		err := validateFormat(test.ext, []byte(test.content))
+		if (err != nil) != test.wantErr {
+			t.Errorf("validateFormat(%q, %q) = %v, want error %v", test.ext, test.content, err, test.wantErr)
+		}
+	}
+}
+
+func TestWriteOutputFileKeepsMode(t *testing.T) {
+	isolateUserDirs(t)
+	dir := t.TempDir()
+	inputTemplate := filepath.Join(dir, "app.conf.jgrt")
+	if err := ioutil.WriteFile(inputTemplate, []byte("password = {{.Password}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := map[string]string{"Password": "secret"}
+
+	tests := []struct {
+		name     string
+		existing os.FileMode // 0 for no existing file
+		want     os.FileMode
+	}{
+		{"new file", 0, 0644},
+		{"private file", 0600, 0600},
+		{"group readable file", 0640, 0640},
+		{"world readable file", 0644, 0644},
+	}
+	for _, test := range tests {
+		outputFile := filepath.Join(dir, "app.conf")
+		os.Remove(outputFile)
+		if test.existing != 0 {
+			if err := ioutil.WriteFile(outputFile, []byte("old\n"), test.existing); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(outputFile, test.existing); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writeOutputFile(&inputTemplate, &outputFile, p); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		info, err := os.Stat(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != test.want {
+			t.Errorf("%s: mode %v, want %v", test.name, info.Mode().Perm(), test.want)
+		}
+	}
+}
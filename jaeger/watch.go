@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Signals that can be sent to reload the consumer of the Output file
+var reloadSignals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  os.Interrupt,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+}
+
+// fileWatcher blocks in Wait until one of the watched files may have changed
+type fileWatcher interface {
+	Wait() error
+}
+
+type reloadAction struct {
+	Signal  os.Signal
+	PID     int
+	PIDFile string
+	Command string
+}
+
+func newReloadAction(signalName string, pid int, pidFile string, command string) (reloadAction, error) {
+	name := strings.TrimPrefix(strings.ToUpper(signalName), "SIG")
+	sig, found := reloadSignals[name]
+	if !found {
+		return reloadAction{}, fmt.Errorf("ERROR: Unknown reload signal: %v", signalName)
+	}
+	return reloadAction{Signal: sig, PID: pid, PIDFile: pidFile, Command: command}, nil
+}
+
+func (r reloadAction) Run() error {
+	if r.Command != "" {
+		jaegerlog.Debug("running reload command", jaegerlog.Operation("reload"), "command", r.Command)
+		cmd := exec.Command("/bin/sh", "-c", r.Command)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("ERROR: Reload command failed: %v", err)
+		}
+	}
+
+	pid := r.PID
+	if r.PIDFile != "" {
+		// Read the PID file every time as the consumer may have been restarted since the last reload
+		buf, err := ioutil.ReadFile(r.PIDFile)
+		if err != nil {
+			return fmt.Errorf("ERROR: Unable to read PID file: %v", r.PIDFile)
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(buf)))
+		if err != nil {
+			return fmt.Errorf("ERROR: Invalid PID file: %v", r.PIDFile)
+		}
+	}
+	if pid != 0 {
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return fmt.Errorf("error: %v", err)
+		}
+		jaegerlog.Debug("sending reload signal", jaegerlog.Operation("reload"), "pid", pid, "signal", r.Signal.String())
+		if err := process.Signal(r.Signal); err != nil {
+			return fmt.Errorf("ERROR: Unable to signal process %d: %v", pid, err)
+		}
+	}
+	return nil
+}
+
+func watchAndRender(inputTemplate *string, jsonGPGDB *string, outputFile *string, entitylist openpgp.EntityList, reload reloadAction, interval time.Duration) error {
+	var watcher fileWatcher
+	watcher, err := newNativeWatcher([]string{*inputTemplate, *jsonGPGDB})
+	if err != nil {
+		jaegerlog.Info("falling back to polling for changes", "reason", err.Error(), "interval", interval)
+		watcher = newPollWatcher([]string{*inputTemplate, *jsonGPGDB}, interval)
+	}
+
+	for {
+		// A render error, eg. from a half written file, is logged and the next change retried
+		if err := renderIfChanged(inputTemplate, jsonGPGDB, outputFile, entitylist, reload); err != nil {
+			jaegerlog.Error(err.Error(), jaegerlog.Operation("watch"), jaegerlog.Template(*inputTemplate), jaegerlog.Store(*jsonGPGDB))
+		}
+		if err := watcher.Wait(); err != nil {
+			return err
+		}
+	}
+}
+
+func renderIfChanged(inputTemplate *string, jsonGPGDB *string, outputFile *string, entitylist openpgp.EntityList, reload reloadAction) error {
+	p, err := parseJaegerDBFile(jsonGPGDB, entitylist)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderTemplate(inputTemplate, p)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ioutil.ReadFile(*outputFile)
+	if err == nil && bytes.Equal(existing, rendered) {
+		jaegerlog.Debug("output file unchanged", jaegerlog.Output(*outputFile))
+		return nil
+	}
+
+	if err := writeOutputFile(inputTemplate, outputFile, p); err != nil {
+		return err
+	}
+	fmt.Println("Wrote file:", *outputFile)
+
+	return reload.Run()
+}
+
+type pollWatcher struct {
+	paths    []string
+	interval time.Duration
+	last     string
+}
+
+func newPollWatcher(paths []string, interval time.Duration) *pollWatcher {
+	w := &pollWatcher{paths: paths, interval: interval}
+	w.last = w.snapshot()
+	return w
+}
+
+func (w *pollWatcher) snapshot() string {
+	var buf bytes.Buffer
+	for _, path := range w.paths {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&buf, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return buf.String()
+}
+
+func (w *pollWatcher) Wait() error {
+	for {
+		time.Sleep(w.interval)
+		if current := w.snapshot(); current != w.last {
+			w.last = current
+			return nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Wait a moment after a change so the rest of a multi step write is picked up by the same render
+const inotifySettle = 100 * time.Millisecond
+
+type inotifyWatcher struct {
+	fd    int
+	dirs  map[int32]string
+	files map[string]bool
+}
+
+func newNativeWatcher(paths []string) (fileWatcher, error) {
+	// Watch the directories rather than the files so replacing a file by rename, as editors do, is seen
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %v", err)
+	}
+
+	w := &inotifyWatcher{fd: fd, dirs: make(map[int32]string), files: make(map[string]bool)}
+	added := make(map[string]bool)
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+		w.files[abs] = true
+
+		dir := filepath.Dir(abs)
+		if added[dir] {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO|syscall.IN_CREATE|syscall.IN_DELETE)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, fmt.Errorf("inotify: %v: %v", dir, err)
+		}
+		w.dirs[int32(wd)] = dir
+		added[dir] = true
+	}
+	return w, nil
+}
+
+func (w *inotifyWatcher) Wait() error {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("inotify: %v", err)
+		}
+
+		changed := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			// struct inotify_event { int wd; uint32 mask; uint32 cookie; uint32 len; char name[]; }
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+nameLen]), "\x00")
+			offset = nameStart + nameLen
+
+			if w.files[filepath.Join(w.dirs[wd], name)] {
+				changed = true
+			}
+		}
+		if changed {
+			time.Sleep(inotifySettle)
+			return nil
+		}
+	}
+}
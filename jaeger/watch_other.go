@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+)
+
+func newNativeWatcher(paths []string) (fileWatcher, error) {
+	return nil, fmt.Errorf("no native file watching on this platform")
+}
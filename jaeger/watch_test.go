@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewReloadAction(t *testing.T) {
+	tests := []struct {
+		signal  string
+		want    os.Signal
+		wantErr bool
+	}{
+		{"HUP", syscall.SIGHUP, false},
+		{"sighup", syscall.SIGHUP, false},
+		{"TERM", syscall.SIGTERM, false},
+		{"KILL", nil, true},
+	}
+	for _, test := range tests {
+		got, err := newReloadAction(test.signal, 0, "", "")
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("newReloadAction(%s): expected an error, got %v", test.signal, got.Signal)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("newReloadAction(%s): unexpected error: %v", test.signal, err)
+			continue
+		}
+		if got.Signal != test.want {
+			t.Errorf("newReloadAction(%s) = %v, want %v", test.signal, got.Signal, test.want)
+		}
+	}
+}
+
+func TestReloadActionRun(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "invalid.pid"), "not a pid\n")
+
+	tests := []struct {
+		name    string
+		action  reloadAction
+		wantErr bool
+	}{
+		{"nothing to do", reloadAction{Signal: syscall.SIGHUP}, false},
+		{"command", reloadAction{Signal: syscall.SIGHUP, Command: "true"}, false},
+		{"failed command", reloadAction{Signal: syscall.SIGHUP, Command: "exit 1"}, true},
+		{"missing PID file", reloadAction{Signal: syscall.SIGHUP, PIDFile: filepath.Join(dir, "missing.pid")}, true},
+		{"invalid PID file", reloadAction{Signal: syscall.SIGHUP, PIDFile: filepath.Join(dir, "invalid.pid")}, true},
+	}
+	for _, test := range tests {
+		err := test.action.Run()
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestRenderIfChanged(t *testing.T) {
+	isolateUserDirs(t)
+	entitylist := testEntityList(t)
+	dir := t.TempDir()
+	inputTemplate := filepath.Join(dir, "app.conf.jgrt")
+	jsonGPGDB := filepath.Join(dir, "app.conf.jgrdb")
+	outputFile := filepath.Join(dir, "app.conf")
+	reloads := filepath.Join(dir, "reloads")
+	writeTestFile(t, inputTemplate, "password = {{.Password}}\n")
+	reload := reloadAction{Signal: syscall.SIGHUP, Command: "echo reload >> " + reloads}
+
+	tests := []struct {
+		name        string
+		password    string
+		wantReloads string
+	}{
+		{"first render", "one", "reload\n"},
+		{"unchanged", "one", "reload\n"},
+		{"changed", "two", "reload\nreload\n"},
+	}
+	for _, test := range tests {
+		writeTestStore(t, jsonGPGDB, map[string]string{"Password": test.password}, entitylist)
+		if err := renderIfChanged(&inputTemplate, &jsonGPGDB, &outputFile, entitylist, reload); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		content, err := ioutil.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "password = " + test.password + "\n"; string(content) != want {
+			t.Errorf("%s: Output file %q, want %q", test.name, content, want)
+		}
+		if got, _ := ioutil.ReadFile(reloads); string(got) != test.wantReloads {
+			t.Errorf("%s: reloads %q, want %q", test.name, got, test.wantReloads)
+		}
+	}
+}
+
+func TestPollWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched")
+	writeTestFile(t, path, "one")
+	w := newPollWatcher([]string{path}, time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- w.Wait() }()
+	writeTestFile(t, path, "changed")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after the file changed")
+	}
+}
@@ -0,0 +1,352 @@
+// Package jaegeraudit is the audit log of a JSON GPG database file, written by jaegerdb and jaegerh whenever they
+// change the store and verified by jaegerdb -audit.
+//
+// The log is a sidecar file with one JSON entry per line. Each entry holds the SHA-256 of the line before it, so an
+// entry can't be changed or removed without breaking the chain, and is signed by the personal key of whoever made
+// the change. Branches of the log are joined by a merge entry, which also holds the hash of the last line of the
+// other branch.
+package jaegeraudit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// Extension is added to the name of the JSON GPG database file to name its audit log
+const Extension = ".log"
+
+// Entry records one operation on one property
+type Entry struct {
+	Time      string
+	Operation string
+	Property  string `json:",omitempty"`
+	Actor     string `json:",omitempty"` // Fingerprint of the signing key
+	ActorName string `json:",omitempty"`
+	Previous  string // SHA-256 of the previous line, empty for the first entry
+	Merged    string `json:",omitempty"` // SHA-256 of the last line of the merged branch
+	Signature string `json:",omitempty"` // Detached signature of the entry without its Signature, base64 encoded
+}
+
+func (e Entry) signedBytes() []byte {
+	e.Signature = ""
+	bytes, _ := json.Marshal(e)
+	return bytes
+}
+
+// Path returns the audit log of a JSON GPG database file, eg. file.txt.jgrdb.log
+func Path(jsonGPGDB string) string {
+	return jsonGPGDB + Extension
+}
+
+func hash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+func signedLine(entry Entry, signer *openpgp.Entity) ([]byte, error) {
+	// Sign the entry when there is a signer and encode it as one line without the line break
+	entry.Time = time.Now().UTC().Format(time.RFC3339)
+	if signer != nil {
+		entry.Actor = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+		entry.ActorName = strings.Join(jaegerstore.IdentityNames(signer), ", ")
+		var signature bytes.Buffer
+		if err := openpgp.DetachSign(&signature, signer, bytes.NewReader(entry.signedBytes()), nil); err != nil {
+			return nil, fmt.Errorf("ERROR: Unable to sign audit log entry: %v", err)
+		}
+		entry.Signature = base64.StdEncoding.EncodeToString(signature.Bytes())
+	} else if usr, err := user.Current(); err == nil {
+		entry.ActorName = usr.Username
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	return line, nil
+}
+
+// Append adds an entry for each property to the audit log of a JSON GPG database file. Without a signer the
+// entries are written unsigned, and Verify reports them as a problem unless requireSigned is false.
+func Append(jsonGPGDB string, operation string, properties []string, signer *openpgp.Entity) error {
+	if len(properties) == 0 {
+		return nil
+	}
+	path := Path(jsonGPGDB)
+	previous, err := lastLine(path)
+	if err != nil {
+		return err
+	}
+	if signer == nil {
+		jaegerlog.Warn("writing unsigned audit log entries, use -sign-key to sign them", jaegerlog.Operation(operation), jaegerlog.Store(jsonGPGDB))
+	}
+
+	var lines bytes.Buffer
+	for _, property := range properties {
+		entry := Entry{Operation: operation, Property: property}
+		if previous != nil {
+			entry.Previous = hash(previous)
+		}
+		line, err := signedLine(entry, signer)
+		if err != nil {
+			return err
+		}
+		lines.Write(line)
+		lines.WriteString("\n")
+		previous = line
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ERROR: Unable to write audit log: %v", err)
+	}
+	if _, err := f.Write(lines.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("ERROR: Unable to write audit log: %v", err)
+	}
+	return f.Close()
+}
+
+func lastLine(path string) ([]byte, error) {
+	lines, err := readLines(path)
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+	return lines[len(lines)-1], nil
+}
+
+func readLines(path string) ([][]byte, error) {
+	// A missing file is an empty log, as git passes for a side that doesn't have the file
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	content = bytes.TrimRight(content, "\n")
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return bytes.Split(content, []byte("\n")), nil
+}
+
+// Merge is the git merge driver for audit logs. The lines of theirs that ours doesn't have are appended to ours
+// followed by a merge entry joining the two branches, so the merged log still verifies. When ours is a prefix of
+// theirs the result is theirs, without a merge entry. Returns the number of lines taken from theirs.
+func Merge(ours string, theirs string, signer *openpgp.Entity) (int, error) {
+	oursLines, err := readLines(ours)
+	if err != nil {
+		return 0, err
+	}
+	theirsLines, err := readLines(theirs)
+	if err != nil {
+		return 0, err
+	}
+
+	inOurs := make(map[string]bool)
+	for _, line := range oursLines {
+		inOurs[string(line)] = true
+	}
+	var added [][]byte
+	for _, line := range theirsLines {
+		if !inOurs[string(line)] {
+			added = append(added, line)
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	merged := append(append([][]byte{}, oursLines...), added...)
+	if len(oursLines) > 0 && len(oursLines)+len(added) != len(theirsLines) {
+		entry := Entry{
+			Operation: "merge",
+			Previous:  hash(added[len(added)-1]),
+			Merged:    hash(oursLines[len(oursLines)-1]),
+		}
+		line, err := signedLine(entry, signer)
+		if err != nil {
+			return 0, err
+		}
+		merged = append(merged, line)
+	} else {
+		merged = theirsLines
+	}
+
+	content := append(bytes.Join(merged, []byte("\n")), '\n')
+	if err := os.WriteFile(ours, content, 0644); err != nil {
+		return 0, fmt.Errorf("error: %v", err)
+	}
+	return len(added), nil
+}
+
+// Summary is the result of verifying an audit log
+type Summary struct {
+	Entries  int
+	Unsigned int
+	Problems []string
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("Summary: %d entries, %d unsigned, %d problems", s.Entries, s.Unsigned, len(s.Problems))
+}
+
+// Verify prints each entry of the audit log of a JSON GPG database file to w and checks the hash chain and the
+// signatures. Every entry but the first must point back to an earlier line, and every line but the last must be
+// pointed to by a later entry, so a changed or removed line, or two branches joined without a merge entry, is
+// reported. Signatures are checked against keyring. With requireSigned, unsigned entries and entries signed by a
+// key that isn't in keyring are problems, except for merge entries, which record no change.
+func Verify(jsonGPGDB string, keyring openpgp.EntityList, requireSigned bool, w io.Writer) (Summary, error) {
+	var summary Summary
+	path := Path(jsonGPGDB)
+	if _, err := os.Stat(path); err != nil {
+		return summary, fmt.Errorf("ERROR: Unable to read audit log: %v", err)
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return summary, err
+	}
+
+	lineNumbers := make(map[string]int)
+	referenced := make(map[int]bool)
+	for i, line := range lines {
+		lineNumber := i + 1
+		summary.Entries++
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			summary.Problems = append(summary.Problems, fmt.Sprintf("%s:%d: invalid entry: %v", path, lineNumber, err))
+			lineNumbers[hash(line)] = lineNumber
+			continue
+		}
+
+		var problems []string
+		if entry.Previous == "" && lineNumber > 1 {
+			problems = append(problems, "chain broken, an earlier entry was changed or removed")
+		}
+		for _, h := range []string{entry.Previous, entry.Merged} {
+			if h == "" {
+				continue
+			}
+			if earlier, ok := lineNumbers[h]; ok {
+				referenced[earlier] = true
+			} else {
+				problems = append(problems, "chain broken, an earlier entry was changed or removed")
+			}
+		}
+
+		status := "unsigned"
+		if entry.Signature == "" {
+			summary.Unsigned++
+		} else {
+			signature, err := base64.StdEncoding.DecodeString(entry.Signature)
+			if err != nil {
+				problems = append(problems, "invalid signature")
+				status = "failed"
+			} else if signer, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(entry.signedBytes()), bytes.NewReader(signature)); err != nil {
+				if err == pgperrors.ErrUnknownIssuer {
+					status = "signed by unknown key"
+				} else {
+					problems = append(problems, "bad signature")
+					status = "failed"
+				}
+			} else if fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint) != entry.Actor {
+				problems = append(problems, "signed by a different key than the actor")
+				status = "failed"
+			} else {
+				status = "verified"
+			}
+		}
+		if requireSigned && entry.Operation != "merge" && (status == "unsigned" || status == "signed by unknown key") {
+			problems = append(problems, status)
+		}
+
+		actor := entry.ActorName
+		if entry.Actor != "" {
+			actor = fmt.Sprintf("%s (%s)", entry.ActorName, entry.Actor)
+		}
+		fmt.Fprintf(w, "%s %-8s %-24s %s [%s]\n", entry.Time, entry.Operation, entry.Property, actor, status)
+		for _, problem := range problems {
+			summary.Problems = append(summary.Problems, fmt.Sprintf("%s:%d: %s", path, lineNumber, problem))
+		}
+		lineNumbers[hash(line)] = lineNumber
+	}
+
+	for lineNumber := 1; lineNumber < len(lines); lineNumber++ {
+		if !referenced[lineNumber] {
+			summary.Problems = append(summary.Problems, fmt.Sprintf("%s:%d: no later entry follows, later entries were removed or a merge entry is missing", path, lineNumber))
+		}
+	}
+	return summary, nil
+}
+
+// Snapshot is a file as it was before an operation, so the operation can be undone when its audit log entries
+// can't be written. A change that isn't in the audit log is never left behind.
+type Snapshot struct {
+	path    string
+	content []byte
+	existed bool
+}
+
+// TakeSnapshot reads path, which may not exist yet
+func TakeSnapshot(path string) (Snapshot, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Snapshot{path: path}, nil
+	}
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("error: %v", err)
+	}
+	return Snapshot{path: path, content: content, existed: true}, nil
+}
+
+// Restore puts the file back as it was, removing it if it didn't exist
+func (s Snapshot) Restore() error {
+	if !s.existed {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error: %v", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(s.path, s.content, 0644); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	return nil
+}
+
+// AppendOrRestore appends the entries for an operation that changed the files of snapshots. When the entries
+// can't be written, the files are restored and the error names the operation as undone.
+func AppendOrRestore(jsonGPGDB string, operation string, properties []string, signer *openpgp.Entity, snapshots ...Snapshot) error {
+	err := Append(jsonGPGDB, operation, properties, signer)
+	if err == nil {
+		return nil
+	}
+	for _, snapshot := range snapshots {
+		if restoreErr := snapshot.Restore(); restoreErr != nil {
+			return fmt.Errorf("%v\n\nError: Unable to undo the change to %v after the audit log failed: %v", err, snapshot.path, restoreErr)
+		}
+	}
+	return fmt.Errorf("%v\n\nError: The change was undone as it could not be recorded in the audit log", err)
+}
+
+// Signer reads the signing key given with -sign-key, or the default personal keyring. With neither, entries are
+// written unsigned and nil is returned without an error.
+func Signer(keyFile string, passphrase string) (*openpgp.Entity, error) {
+	signer, err := jaegerstore.SigningKey(keyFile, passphrase)
+	if err == jaegerstore.ErrNoSigningKey {
+		return nil, nil
+	}
+	return signer, err
+}
@@ -0,0 +1,252 @@
+package jaegeraudit
+
+import (
+	"bytes"
+	"crypto"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEntityList(t *testing.T) openpgp.EntityList {
+	// A small, throwaway key pair. Test keys are kept short so generating them is fast.
+	t.Helper()
+	entity, err := openpgp.NewEntity("Jaeger Test", "", "jaeger@example.com", &packet.Config{RSABits: 1024, DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return openpgp.EntityList{entity}
+}
+
+func appendTestAuditLog(t *testing.T, jsonGPGDB string, signer *openpgp.Entity, properties ...string) {
+	t.Helper()
+	if err := Append(jsonGPGDB, "add", properties, signer); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func copyTestFile(t *testing.T, from string, to string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(to, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkAuditProblems(t *testing.T, name string, problems []string, want []string) {
+	t.Helper()
+	if len(problems) != len(want) {
+		t.Errorf("%s: problems %q, want %q", name, problems, want)
+		return
+	}
+	for i, problem := range problems {
+		if !strings.HasSuffix(problem, want[i]) {
+			t.Errorf("%s: problem %q, want %q", name, problem, want[i])
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	entitylist := testEntityList(t)
+	other := testEntityList(t)
+	signer := entitylist[0]
+
+	tests := []struct {
+		name          string
+		write         func(jsonGPGDB string)
+		requireSigned bool
+		want          []string
+	}{
+		{
+			"signed chain",
+			func(jsonGPGDB string) {
+				appendTestAuditLog(t, jsonGPGDB, signer, "A", "B")
+				appendTestAuditLog(t, jsonGPGDB, signer, "C")
+			},
+			true,
+			nil,
+		},
+		{
+			"changed line",
+			func(jsonGPGDB string) {
+				appendTestAuditLog(t, jsonGPGDB, signer, "A", "B")
+				path := Path(jsonGPGDB)
+				content, _ := ioutil.ReadFile(path)
+				ioutil.WriteFile(path, bytes.Replace(content, []byte(`"Property":"A"`), []byte(`"Property":"X"`), 1), 0644)
+			},
+			true,
+			[]string{":1: bad signature", ":2: chain broken, an earlier entry was changed or removed", ":1: no later entry follows, later entries were removed or a merge entry is missing"},
+		},
+		{
+			"removed line",
+			func(jsonGPGDB string) {
+				appendTestAuditLog(t, jsonGPGDB, signer, "A", "B", "C")
+				path := Path(jsonGPGDB)
+				content, _ := ioutil.ReadFile(path)
+				lines := strings.Split(string(content), "\n")
+				ioutil.WriteFile(path, []byte(lines[0]+"\n"+lines[2]+"\n"), 0644)
+			},
+			true,
+			[]string{":2: chain broken, an earlier entry was changed or removed", ":1: no later entry follows, later entries were removed or a merge entry is missing"},
+		},
+		{
+			"unsigned",
+			func(jsonGPGDB string) {
+				appendTestAuditLog(t, jsonGPGDB, signer, "A")
+				appendTestAuditLog(t, jsonGPGDB, nil, "B")
+			},
+			true,
+			[]string{":2: unsigned"},
+		},
+		{
+			"unsigned without require signed",
+			func(jsonGPGDB string) {
+				appendTestAuditLog(t, jsonGPGDB, nil, "A")
+			},
+			false,
+			nil,
+		},
+		{
+			"signed by unknown key",
+			func(jsonGPGDB string) {
+				appendTestAuditLog(t, jsonGPGDB, other[0], "A")
+			},
+			true,
+			[]string{":1: signed by unknown key"},
+		},
+	}
+
+	for _, test := range tests {
+		jsonGPGDB := filepath.Join(t.TempDir(), "test.txt.jgrdb")
+		test.write(jsonGPGDB)
+		summary, err := Verify(jsonGPGDB, entitylist, test.requireSigned, ioutil.Discard)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		checkAuditProblems(t, test.name, summary.Problems, test.want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	entitylist := testEntityList(t)
+	signer := entitylist[0]
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.jgrdb")
+	ours := filepath.Join(dir, "ours.jgrdb")
+	theirs := filepath.Join(dir, "theirs.jgrdb")
+
+	// Both branches add an entry after the common one
+	appendTestAuditLog(t, base, signer, "A")
+	copyTestFile(t, Path(base), Path(ours))
+	copyTestFile(t, Path(base), Path(theirs))
+	appendTestAuditLog(t, ours, signer, "B")
+	appendTestAuditLog(t, theirs, signer, "C")
+
+	// Resolving the conflict by keeping both lines leaves a fork
+	fork := filepath.Join(dir, "fork.jgrdb")
+	copyTestFile(t, Path(ours), Path(fork))
+	theirsContent, err := ioutil.ReadFile(Path(theirs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forkContent, _ := ioutil.ReadFile(Path(fork))
+	forkContent = append(forkContent, theirsContent[bytes.IndexByte(theirsContent, '\n')+1:]...)
+	if err := ioutil.WriteFile(Path(fork), forkContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := Verify(fork, entitylist, true, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAuditProblems(t, "fork", summary.Problems, []string{":2: no later entry follows, later entries were removed or a merge entry is missing"})
+
+	added, err := Merge(Path(ours), Path(theirs), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("Merge() took %d entries from theirs, want 1", added)
+	}
+	summary, err = Verify(ours, entitylist, true, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAuditProblems(t, "merged", summary.Problems, nil)
+	if summary.Entries != 4 {
+		t.Errorf("merged log has %d entries, want 4", summary.Entries)
+	}
+	last, err := lastLine(Path(ours))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(last, []byte(`"Operation":"merge"`)) {
+		t.Errorf("merged log ends with %s, want a merge entry", last)
+	}
+
+	// Merging again takes nothing, and a log that is a prefix of theirs becomes theirs
+	merged, _ := ioutil.ReadFile(Path(ours))
+	if added, err := Merge(Path(ours), Path(theirs), signer); err != nil || added != 0 {
+		t.Errorf("Merge() again = %d, %v, want 0", added, err)
+	}
+	if added, err := Merge(Path(base), Path(ours), signer); err != nil || added != 3 {
+		t.Errorf("Merge() of a prefix = %d, %v, want 3", added, err)
+	}
+	if content, _ := ioutil.ReadFile(Path(base)); string(content) != string(merged) {
+		t.Errorf("merging into a prefix gave:\n%s\nwant:\n%s", content, merged)
+	}
+}
+
+func TestAppendOrRestore(t *testing.T) {
+	dir := t.TempDir()
+	jsonGPGDB := filepath.Join(dir, "test.txt.jgrdb")
+	template := filepath.Join(dir, "test.txt.jgrt")
+	if err := ioutil.WriteFile(jsonGPGDB, []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	storeSnapshot, err := TakeSnapshot(jsonGPGDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templateSnapshot, err := TakeSnapshot(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The audit log can be written, the change is kept
+	ioutil.WriteFile(jsonGPGDB, []byte("after"), 0644)
+	if err := AppendOrRestore(jsonGPGDB, "add", []string{"A"}, nil, storeSnapshot); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(jsonGPGDB); string(content) != "after" {
+		t.Errorf("store = %q after the audit log was written, want %q", content, "after")
+	}
+
+	// The audit log can't be written, the store and the new template are put back as they were
+	storeSnapshot, err = TakeSnapshot(jsonGPGDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(jsonGPGDB, []byte("changed"), 0644)
+	ioutil.WriteFile(template, []byte("{{.A}}"), 0644)
+	os.Remove(Path(jsonGPGDB))
+	if err := os.Mkdir(Path(jsonGPGDB), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendOrRestore(jsonGPGDB, "add", []string{"B"}, nil, storeSnapshot, templateSnapshot); err == nil {
+		t.Fatal("AppendOrRestore(): expected an error when the audit log can't be written")
+	}
+	if content, _ := ioutil.ReadFile(jsonGPGDB); string(content) != "after" {
+		t.Errorf("store = %q after the audit log failed, want %q", content, "after")
+	}
+	if _, err := os.Stat(template); !os.IsNotExist(err) {
+		t.Errorf("template %s was kept after the audit log failed, want it removed", template)
+	}
+}
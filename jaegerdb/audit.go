@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"golang.org/x/crypto/openpgp"
+	"os"
+	"os/user"
+)
+
+func loadAuditKeyRing(keyringFile *string, secretEntitylist openpgp.EntityList, signer *openpgp.Entity) openpgp.EntityList {
+	// Keys that audit log signatures are checked against: the signing key, the secret key, the public key given
+	// with -k and the default public keyrings, which hold the keys of the other operators
+	keyring := append(openpgp.EntityList{}, secretEntitylist...)
+	if signer != nil {
+		keyring = append(keyring, signer)
+	}
+	var paths []string
+	if *keyringFile != "" {
+		paths = append(paths, *keyringFile)
+	}
+	if usr, err := user.Current(); err == nil {
+		paths = append(paths, fmt.Sprintf("%v/.gnupg/jaeger_pubring.gpg", usr.HomeDir), fmt.Sprintf("%v/.gnupg/pubring.gpg", usr.HomeDir))
+	}
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		var entitylist openpgp.EntityList
+		if i == 0 && *keyringFile != "" {
+			entitylist, err = openpgp.ReadArmoredKeyRing(f)
+		} else {
+			entitylist, err = openpgp.ReadKeyRing(f)
+		}
+		f.Close()
+		if err != nil {
+			jaegerlog.Warn("unable to read keyring", "keyring", path, "error", err)
+			continue
+		}
+		keyring = append(keyring, entitylist...)
+	}
+	return keyring
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+)
+
+func loadSecretKeyRing(secretKeyringFile *string, passphraseKeyring *string) (openpgp.EntityList, error) {
+	// Read the armored private key or the default secret keyring and decrypt it. Operations that can work without
+	// decrypted values get nil when no secret keyring is found.
+	entitylist, err := jaegerstore.SecretKeyRing(*secretKeyringFile, *passphraseKeyring)
+	if err == jaegerstore.ErrNoSecretKeyRing {
+		return nil, nil
+	}
+	return entitylist, err
+}
+
+func loadOptionalSecretKeyRing(secretKeyringFile *string, passphraseKeyring *string) openpgp.EntityList {
+	// For operations that only use the secret key to avoid needless work, a key that can't be used is a warning
+	entitylist, err := loadSecretKeyRing(secretKeyringFile, passphraseKeyring)
+	if err != nil {
+		jaegerlog.Warn(err.Error())
+		return nil
+	}
+	return entitylist
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"io/ioutil"
+)
+
+func formatJaegerDB(jsonGPGDB string, check bool) (bool, error) {
+	// Rewrite a JSON GPG database file in canonical form, or with check only report whether it would change
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return false, fmt.Errorf("ERROR: Unable to read JSON GPG DB file: %v", jsonGPGDB)
+	}
+
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return false, fmt.Errorf("error: %v: %v", jsonGPGDB, err)
+	}
+	formatted, err := jaegerstore.Marshal(j)
+	if err != nil {
+		return false, fmt.Errorf("error: %v", err)
+	}
+
+	if bytes.Equal(formatted, jsonGPGDBBuffer) {
+		return false, nil
+	}
+	if !check {
+		if err := ioutil.WriteFile(jsonGPGDB, formatted, 0644); err != nil {
+			return false, fmt.Errorf("error: %v", err)
+		}
+	}
+	return true, nil
+}
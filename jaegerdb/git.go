@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegeraudit"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const gitAttributesLine = "*" + jaegerDBExtension + " diff=jaeger merge=jaeger"
+const gitAuditAttributesLine = "*" + jaegerDBExtension + jaegeraudit.Extension + " merge=jaeger-audit"
+
+func textconvJaegerDB(jsonGPGDB string, entitylist openpgp.EntityList, showSecrets bool) ([]byte, error) {
+	// One sorted "Name = value" line per property, so git diffs show which properties changed rather than
+	// base64 churn. Values are shown as a fingerprint unless showSecrets is set, see textconvValue.
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Unable to read JSON GPG DB file: %v", jsonGPGDB)
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+
+	sort.SliceStable(j.Properties, func(a, b int) bool {
+		return j.Properties[a].Name < j.Properties[b].Name
+	})
+
+	var buf bytes.Buffer
+	for _, property := range j.Properties {
+		fmt.Fprintf(&buf, "%s = %s\n", property.Name, textconvValue(property, entitylist, showSecrets))
+	}
+	return buf.Bytes(), nil
+}
+
+func textconvValue(property jaegerstore.Property, entitylist openpgp.EntityList, showSecrets bool) string {
+	// With the secret key a value is shown as an HMAC keyed by a secret derived from the private key, so equal
+	// values have equal fingerprints but a fingerprint can't be checked against guessed values without the key.
+	// Without it, or when no secret can be derived, the ciphertext is fingerprinted instead, which still shows
+	// that a value changed.
+	ciphertextFingerprint := fmt.Sprintf("enc:%x", sha256.Sum256([]byte(property.EncryptedValue)))[:len("enc:")+16]
+	if entitylist == nil {
+		return ciphertextFingerprint
+	}
+	value, err := jaegerstore.Decrypt(property.EncryptedValue, entitylist)
+	if err != nil {
+		return "<undecryptable> " + ciphertextFingerprint
+	}
+	if !showSecrets {
+		key := fingerprintKey(entitylist)
+		if key == nil {
+			return ciphertextFingerprint
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return fmt.Sprintf("hmac:%x", mac.Sum(nil))[:len("hmac:")+16]
+	}
+	// Keep each property on one line
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+func fingerprintKey(entitylist openpgp.EntityList) []byte {
+	// A secret derived from the first decrypted private key in the keyring, or nil
+	for _, entity := range entitylist {
+		keys := []*packet.PrivateKey{entity.PrivateKey}
+		for _, subkey := range entity.Subkeys {
+			keys = append(keys, subkey.PrivateKey)
+		}
+		for _, key := range keys {
+			if key == nil || key.Encrypted {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := key.Serialize(&buf); err != nil {
+				continue
+			}
+			mac := hmac.New(sha256.New, []byte("jaeger textconv fingerprint"))
+			mac.Write(buf.Bytes())
+			return mac.Sum(nil)
+		}
+	}
+	return nil
+}
+
+func installGit(command string) error {
+	// Register the textconv diff driver and the merge drivers in the local git config and mark .jgrdb files and
+	// their audit logs to use them
+	top, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return fmt.Errorf("ERROR: Not in a git repository: %v", err)
+	}
+
+	config := [][2]string{
+		{"diff.jaeger.textconv", command + " -textconv"},
+		{"merge.jaeger.name", "Jaeger JSON GPG database merge by property"},
+		{"merge.jaeger.driver", command + " -merge %O %A %B"},
+		{"merge.jaeger-audit.name", "Jaeger audit log merge"},
+		{"merge.jaeger-audit.driver", command + " -merge-audit %O %A %B"},
+	}
+	for _, entry := range config {
+		if output, err := exec.Command("git", "config", entry[0], entry[1]).CombinedOutput(); err != nil {
+			return fmt.Errorf("ERROR: git config: %v\n%s", err, output)
+		}
+		fmt.Printf("Set git config %s = %s\n", entry[0], entry[1])
+	}
+
+	gitAttributes := filepath.Join(strings.TrimSpace(string(top)), ".gitattributes")
+	if err := addGitAttribute(gitAttributes, gitAttributesLine); err != nil {
+		return err
+	}
+	return addGitAttribute(gitAttributes, gitAuditAttributesLine)
+}
+
+func addGitAttribute(gitAttributes string, line string) error {
+	// Append the line, or replace an earlier line for the same pattern
+	content, err := ioutil.ReadFile(gitAttributes)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error: %v", err)
+	}
+	pattern := strings.Fields(line)[0]
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	replaced := false
+	for i, existing := range lines {
+		if fields := strings.Fields(existing); len(fields) > 0 && fields[0] == pattern {
+			if strings.TrimSpace(existing) == line {
+				return nil
+			}
+			lines[i] = line
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, line)
+	}
+	if lines[0] == "" {
+		lines = lines[1:]
+	}
+	content = []byte(strings.Join(lines, "\n") + "\n")
+	if err := ioutil.WriteFile(gitAttributes, content, 0644); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	fmt.Println("Wrote file:", gitAttributes)
+	return nil
+}
+
+func shellQuote(s string) string {
+	// Single quotes preserve everything literally except a single quote, which is closed, escaped and reopened
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"strings"
+	"testing"
+)
+
+func testEntityList(t *testing.T) openpgp.EntityList {
+	// A small, throwaway key pair. Test keys are kept short so generating them is fast.
+	t.Helper()
+	entity, err := openpgp.NewEntity("Jaeger Test", "", "jaeger@example.com", &packet.Config{RSABits: 1024, DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return openpgp.EntityList{entity}
+}
+
+func testEncrypt(t *testing.T, value string, entitylist openpgp.EntityList) string {
+	t.Helper()
+	encrypted, err := jaegerstore.Encrypt(value, entitylist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted
+}
+
+func TestTextconvValue(t *testing.T) {
+	entitylist := testEntityList(t)
+	other := testEntityList(t)
+	first := jaegerstore.Property{Name: "A", EncryptedValue: testEncrypt(t, "secret", entitylist)}
+	same := jaegerstore.Property{Name: "A", EncryptedValue: testEncrypt(t, "secret", entitylist)}
+	changed := jaegerstore.Property{Name: "A", EncryptedValue: testEncrypt(t, "changed", entitylist)}
+	multiline := jaegerstore.Property{Name: "A", EncryptedValue: testEncrypt(t, "a\nb", entitylist)}
+
+	fingerprint := textconvValue(first, entitylist, false)
+	if !strings.HasPrefix(fingerprint, "hmac:") || len(fingerprint) != len("hmac:")+16 {
+		t.Errorf("textconvValue() = %q, want a short hmac: fingerprint", fingerprint)
+	}
+	if unkeyed := fmt.Sprintf("%x", sha256.Sum256([]byte("secret")))[:16]; strings.Contains(fingerprint, unkeyed) {
+		t.Errorf("textconvValue() = %q is the unkeyed SHA-256 of the value", fingerprint)
+	}
+	if got := textconvValue(same, entitylist, false); got != fingerprint {
+		t.Errorf("textconvValue() of the same value encrypted again = %q, want %q", got, fingerprint)
+	}
+	if got := textconvValue(changed, entitylist, false); got == fingerprint {
+		t.Errorf("textconvValue() of a changed value = %q, want a different fingerprint", got)
+	}
+
+	tests := []struct {
+		name        string
+		property    jaegerstore.Property
+		entitylist  openpgp.EntityList
+		showSecrets bool
+		want        string
+	}{
+		{"show secrets", first, entitylist, true, "secret"},
+		{"show secrets quotes line breaks", multiline, entitylist, true, `"a\nb"`},
+		{"no secret key", first, nil, false, "enc:"},
+		{"no secret key with show secrets", first, nil, true, "enc:"},
+		{"wrong secret key", first, other, false, "<undecryptable> enc:"},
+	}
+	for _, test := range tests {
+		got := textconvValue(test.property, test.entitylist, test.showSecrets)
+		if test.showSecrets && test.entitylist != nil {
+			if got != test.want {
+				t.Errorf("%s: textconvValue() = %q, want %q", test.name, got, test.want)
+			}
+			continue
+		}
+		if !strings.HasPrefix(got, test.want) || strings.Contains(got, "secret") {
+			t.Errorf("%s: textconvValue() = %q, want a %q fingerprint", test.name, got, test.want)
+		}
+	}
+	if textconvValue(first, nil, false) == textconvValue(same, nil, false) {
+		t.Errorf("textconvValue() without a key gave the same fingerprint for two encryptions")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Number of previous values kept per property unless -history-size is set
+const defaultHistorySize = 5
+
+func historyJaegerDB(key string, jsonGPGDB string, secretEntitylist openpgp.EntityList, w io.Writer) error {
+	// List the versions of a property, newest first. Values are shown as fingerprints, keyed by the secret key
+	// when it is available, so versions holding the same value can be told apart.
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+
+	property := j.Find(key)
+	if property == nil {
+		return fmt.Errorf("\n\nError: Property '%s' not found.", key)
+	}
+
+	printVersion := func(version int, encryptedValue string, updated string, current bool) {
+		if updated == "" {
+			updated = "-"
+		}
+		line := fmt.Sprintf("%4d  %-20s  %s", version, updated, textconvValue(jaegerstore.Property{EncryptedValue: encryptedValue}, secretEntitylist, false))
+		if current {
+			line += "  (current)"
+		}
+		fmt.Fprintln(w, line)
+	}
+	printVersion(property.CurrentVersion(), property.EncryptedValue, property.Updated, true)
+	for i := len(property.History) - 1; i >= 0; i-- {
+		v := property.History[i]
+		printVersion(v.Version, v.EncryptedValue, v.Updated, false)
+	}
+	return nil
+}
+
+func rollbackJaegerDB(key string, version int, jsonGPGDB string, historySize int) error {
+	// Restore a previous version of a property. The restored value becomes a new version, so the value being
+	// replaced is kept and the rollback can itself be rolled back.
+	start := time.Now()
+
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+
+	property := j.Find(key)
+	if property == nil {
+		return fmt.Errorf("\n\nError: Property '%s' not found.", key)
+	}
+	if version == property.CurrentVersion() {
+		return fmt.Errorf("\n\nError: Version %d is the current version of property '%s'.", version, key)
+	}
+
+	var previous *jaegerstore.PropertyVersion
+	for i := range property.History {
+		if property.History[i].Version == version {
+			previous = &property.History[i]
+		}
+	}
+	if previous == nil {
+		return fmt.Errorf("\n\nError: Version %d of property '%s' not found. Use -history to list the versions kept.", version, key)
+	}
+	property.SetValue(previous.EncryptedValue, historySize)
+
+	bytes, err := jaegerstore.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	if err := ioutil.WriteFile(jsonGPGDB, bytes, 0644); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+
+	jaegerlog.Info("rolled back property", jaegerlog.Operation("rollback"), jaegerlog.Store(jsonGPGDB), jaegerlog.Property(key),
+		"version", version, jaegerlog.Duration(start))
+	return nil
+}
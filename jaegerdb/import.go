@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policies for imported properties that already exist in the JSON GPG database
+const (
+	importPolicyError     = "error"
+	importPolicyOverwrite = "overwrite"
+	importPolicySkip      = "skip"
+)
+
+type importEntry struct {
+	Name  string
+	Value string
+}
+
+type importSummary struct {
+	Added     []string
+	Changed   []string
+	Unchanged []string
+	Skipped   []string
+}
+
+func (s importSummary) String() string {
+	return fmt.Sprintf("Imported properties: %d added, %d changed, %d unchanged, %d skipped", len(s.Added), len(s.Changed), len(s.Unchanged), len(s.Skipped))
+}
+
+func importJaegerDB(importFile *string, jsonGPGDB *string, policy string, entitylist openpgp.EntityList, secretEntitylist openpgp.EntityList, historySize int) (importSummary, error) {
+	start := time.Now()
+	var summary importSummary
+
+	entries, err := readImportFile(importFile)
+	if err != nil {
+		return summary, err
+	}
+
+	// json handling
+	jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB)
+	if err != nil {
+		return summary, fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+	}
+
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+	jaegerlog.Debug("json unmarshal", jaegerlog.Store(*jsonGPGDB), "properties", len(j.Properties))
+
+	existing := make(map[string]int)
+	for i, property := range j.Properties {
+		existing[property.Name] = i
+	}
+
+	if policy == importPolicyError {
+		var conflicts []string
+		for _, entry := range entries {
+			if _, found := existing[entry.Name]; found {
+				conflicts = append(conflicts, entry.Name)
+			}
+		}
+		if len(conflicts) > 0 {
+			return summary, fmt.Errorf("\n\nError: Properties already exist: %s. Use -overwrite or -skip-existing.", strings.Join(conflicts, ", "))
+		}
+	}
+
+	for _, entry := range entries {
+		i, found := existing[entry.Name]
+		if found && policy == importPolicySkip {
+			jaegerlog.Debug("skipping existing property", jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(entry.Name))
+			summary.Skipped = append(summary.Skipped, entry.Name)
+			continue
+		}
+
+		if found && unchangedValue(j.Properties[i], entry.Value, secretEntitylist) {
+			summary.Unchanged = append(summary.Unchanged, entry.Name)
+			continue
+		}
+
+		encryptedValue, err := jaegerstore.Encrypt(entry.Value, entitylist)
+		if err != nil {
+			return summary, err
+		}
+		if found {
+			j.Properties[i].SetValue(encryptedValue, historySize)
+			summary.Changed = append(summary.Changed, entry.Name)
+		} else {
+			p := jaegerstore.Property{Name: entry.Name}
+			p.SetValue(encryptedValue, historySize)
+			existing[entry.Name] = len(j.Properties)
+			j.Properties = append(j.Properties, p)
+			summary.Added = append(summary.Added, entry.Name)
+		}
+	}
+
+	bytes, err := jaegerstore.Marshal(j)
+	if err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+
+	jaegerlog.Debug("marshalled JSON GPG database", jaegerlog.Store(*jsonGPGDB), "bytes", len(bytes))
+
+	// Writing file
+	// To handle large files, use a file buffer: http://stackoverflow.com/a/9739903/603745
+	if err := ioutil.WriteFile(*jsonGPGDB, bytes, 0644); err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+
+	jaegerlog.Info("imported properties", jaegerlog.Operation("import"), jaegerlog.Store(*jsonGPGDB), "file", *importFile,
+		"added", len(summary.Added), "changed", len(summary.Changed), "unchanged", len(summary.Unchanged), "skipped", len(summary.Skipped), jaegerlog.Duration(start))
+	return summary, nil
+}
+
+func readImportFile(importFile *string) ([]importEntry, error) {
+	// The format is chosen by file extension
+	buf, err := ioutil.ReadFile(*importFile)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Unable to read import file: %v", *importFile)
+	}
+
+	var entries []importEntry
+	switch strings.ToLower(filepath.Ext(*importFile)) {
+	case ".env":
+		entries, err = parseDotenv(buf)
+	case ".json":
+		entries, err = parseFlatJSON(buf)
+	case ".yaml", ".yml":
+		entries, err = parseFlatYAML(buf)
+	case ".properties":
+		entries, err = parseJavaProperties(buf)
+	default:
+		return nil, fmt.Errorf("ERROR: Unknown import file format: %v. Use a .env, .json, .yaml or .properties file", *importFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: %v: %v", *importFile, err)
+	}
+
+	return dedupeImportEntries(entries), nil
+}
+
+func dedupeImportEntries(entries []importEntry) []importEntry {
+	// The last value for a repeated name wins, in the position it was first seen
+	index := make(map[string]int)
+	var deduped []importEntry
+	for _, entry := range entries {
+		if i, found := index[entry.Name]; found {
+			deduped[i].Value = entry.Value
+			continue
+		}
+		index[entry.Name] = len(deduped)
+		deduped = append(deduped, entry)
+	}
+	return deduped
+}
+
+func parseDotenv(buf []byte) ([]importEntry, error) {
+	// KEY=VALUE lines with optional 'export ', single quoted literal or double quoted escaped values
+	var entries []importEntry
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		s := strings.SplitN(line, "=", 2)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+		name := strings.TrimSpace(s[0])
+		value := strings.TrimSpace(s[1])
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNumber)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, "\""):
+			unquoted, _, err := jaegertemplate.UnquoteDotenv(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			value = unquoted
+		default:
+			// Unquoted values end at an inline comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		entries = append(entries, importEntry{Name: name, Value: value})
+	}
+	return entries, scanner.Err()
+}
+
+func unquotePrefix(s string) (string, error) {
+	// Unquote the double quoted string at the start of s, ignoring anything after the closing quote
+	prefix, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid double quoted value")
+	}
+	return strconv.Unquote(prefix)
+}
+
+func parseFlatJSON(buf []byte) ([]importEntry, error) {
+	// A single JSON object of names to strings, numbers or booleans. Key order is preserved.
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var entries []importEntry
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := token.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case string:
+			entries = append(entries, importEntry{Name: name, Value: v})
+		case json.Number:
+			entries = append(entries, importEntry{Name: name, Value: v.String()})
+		case bool:
+			entries = append(entries, importEntry{Name: name, Value: strconv.FormatBool(v)})
+		default:
+			return nil, fmt.Errorf("property '%s': only string, number and boolean values can be imported", name)
+		}
+	}
+	return entries, nil
+}
+
+func parseFlatYAML(buf []byte) ([]importEntry, error) {
+	// A flat mapping of 'key: value' lines. Nested mappings, lists and block scalars are not supported.
+	var entries []importEntry
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		if raw[0] == ' ' || raw[0] == '\t' || strings.HasPrefix(line, "- ") {
+			return nil, fmt.Errorf("line %d: only a flat mapping of 'key: value' lines can be imported", lineNumber)
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected 'key: value'", lineNumber)
+		}
+		name := strings.Trim(strings.TrimSpace(line[:i]), "\"'")
+		value := strings.TrimSpace(line[i+1:])
+		switch {
+		case value == "" || value == "|" || value == ">" || strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			return nil, fmt.Errorf("line %d: property '%s' has no inline value", lineNumber, name)
+		case strings.HasPrefix(value, "'"):
+			end := strings.LastIndex(value, "'")
+			if end == 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNumber)
+			}
+			value = strings.Replace(value[1:end], "''", "'", -1)
+		case strings.HasPrefix(value, "\""):
+			unquoted, err := unquotePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			value = unquoted
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		entries = append(entries, importEntry{Name: name, Value: value})
+	}
+	return entries, scanner.Err()
+}
+
+func parseJavaProperties(buf []byte) ([]importEntry, error) {
+	// Java .properties: 'key=value', 'key: value' or 'key value', with '\' line continuations and escapes
+	var entries []importEntry
+	var logical string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// An odd number of trailing backslashes continues the logical line
+		trailing := len(line) - len(strings.TrimRight(line, "\\"))
+		if trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		name, value := splitJavaProperty(logical)
+		logical = ""
+		entries = append(entries, importEntry{Name: unescapeJavaProperty(name), Value: unescapeJavaProperty(value)})
+	}
+	if logical != "" {
+		name, value := splitJavaProperty(logical)
+		entries = append(entries, importEntry{Name: unescapeJavaProperty(name), Value: unescapeJavaProperty(value)})
+	}
+	return entries, scanner.Err()
+}
+
+func splitJavaProperty(line string) (string, string) {
+	// The key ends at the first unescaped '=', ':' or whitespace
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			value := strings.TrimLeft(line[i:], " \t\f")
+			if value != "" && (value[0] == '=' || value[0] == ':') {
+				value = strings.TrimLeft(value[1:], " \t\f")
+			}
+			return line[:i], value
+		}
+	}
+	return line, ""
+}
+
+func unescapeJavaProperty(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					buf.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			buf.WriteByte('u')
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String()
+}
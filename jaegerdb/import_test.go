@@ -0,0 +1,177 @@
+package main
+
+import (
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []importEntry
+		wantErr bool
+	}{
+		{"plain", "A=1\nB=two words\n", []importEntry{{"A", "1"}, {"B", "two words"}}, false},
+		{"comments and blank lines", "# comment\n\nA=1 # inline\n", []importEntry{{"A", "1"}}, false},
+		{"export prefix", "export A=1\n", []importEntry{{"A", "1"}}, false},
+		{"single quoted", "A='$literal \\n # kept'\n", []importEntry{{"A", `$literal \n # kept`}}, false},
+		{"double quoted", `A="line\nbreak" # comment`, []importEntry{{"A", "line\nbreak"}}, false},
+		{"spaces around equals", "A = 1\n", []importEntry{{"A", "1"}}, false},
+		{"equals in value", "URL=a=b\n", []importEntry{{"URL", "a=b"}}, false},
+		{"empty value", "A=\n", []importEntry{{"A", ""}}, false},
+		{"missing equals", "A\n", nil, true},
+		{"unterminated single quote", "A='x\n", nil, true},
+		{"unterminated double quote", "A=\"x\n", nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseDotenv([]byte(test.input))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseDotenvRoundTrip(t *testing.T) {
+	// jaeger -export dotenv writes double quoted values with jaegertemplate.DotenvString
+	values := []string{"plain", "", "it's $HOME", "`cmd` \\ \"q\" # not a comment", "line\nbreak\r\ttab", "kept \\d"}
+	for _, value := range values {
+		quoted, err := jaegertemplate.DotenvString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseDotenv([]byte("A=" + quoted + " # comment\n"))
+		if err != nil {
+			t.Errorf("parseDotenv(A=%s): unexpected error: %v", quoted, err)
+			continue
+		}
+		if want := []importEntry{{"A", value}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("parseDotenv(A=%s) = %q, want %q", quoted, got, want)
+		}
+	}
+}
+
+func TestParseFlatJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []importEntry
+		wantErr bool
+	}{
+		{"strings in order", `{"B": "2", "A": "1"}`, []importEntry{{"B", "2"}, {"A", "1"}}, false},
+		{"numbers and booleans", `{"Port": 5432, "Ratio": 1.50, "Debug": false}`, []importEntry{{"Port", "5432"}, {"Ratio", "1.50"}, {"Debug", "false"}}, false},
+		{"escapes", `{"A": "x\"y\u00e9"}`, []importEntry{{"A", "x\"yé"}}, false},
+		{"empty object", `{}`, nil, false},
+		{"nested object", `{"A": {"B": "1"}}`, nil, true},
+		{"array value", `{"A": ["1"]}`, nil, true},
+		{"null value", `{"A": null}`, nil, true},
+		{"not an object", `["A"]`, nil, true},
+		{"invalid JSON", `{"A": }`, nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseFlatJSON([]byte(test.input))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseFlatYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []importEntry
+		wantErr bool
+	}{
+		{"plain", "a: 1\nb: two words\n", []importEntry{{"a", "1"}, {"b", "two words"}}, false},
+		{"document start and comments", "---\n# comment\na: 1 # inline\n", []importEntry{{"a", "1"}}, false},
+		{"single quoted", "a: 'it''s # not a comment'\n", []importEntry{{"a", "it's # not a comment"}}, false},
+		{"double quoted", "a: \"tab\\there\"\n", []importEntry{{"a", "tab\there"}}, false},
+		{"quoted key", "\"a b\": 1\n", []importEntry{{"a b", "1"}}, false},
+		{"colon in value", "url: http://host:80\n", []importEntry{{"url", "http://host:80"}}, false},
+		{"nested mapping", "a:\n  b: 1\n", nil, true},
+		{"list", "- a\n", nil, true},
+		{"block scalar", "a: |\n  text\n", nil, true},
+		{"missing colon", "a\n", nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseFlatYAML([]byte(test.input))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseJavaProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []importEntry
+		wantErr bool
+	}{
+		{"separators", "a=1\nb: 2\nc 3\n", []importEntry{{"a", "1"}, {"b", "2"}, {"c", "3"}}, false},
+		{"comments", "# comment\n! comment\na=1\n", []importEntry{{"a", "1"}}, false},
+		{"whitespace around separator", "a = 1\n", []importEntry{{"a", "1"}}, false},
+		{"continuation", "a=one \\\n    two\n", []importEntry{{"a", "one two"}}, false},
+		{"escaped backslash is not a continuation", "a=x\\\\\nb=2\n", []importEntry{{"a", `x\`}, {"b", "2"}}, false},
+		{"escaped separator in key", "a\\=b=1\n", []importEntry{{"a=b", "1"}}, false},
+		{"escapes", "a=tab\\tnew\\nline\\u0041\n", []importEntry{{"a", "tab\tnew\nlineA"}}, false},
+		{"no value", "a\n", []importEntry{{"a", ""}}, false},
+		{"continuation at end of file", "a=1\\", []importEntry{{"a", "1"}}, false},
+	}
+	for _, test := range tests {
+		got, err := parseJavaProperties([]byte(test.input))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDedupeImportEntries(t *testing.T) {
+	got := dedupeImportEntries([]importEntry{{"A", "1"}, {"B", "2"}, {"A", "3"}})
+	want := []importEntry{{"A", "3"}, {"B", "2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeImportEntries() = %q, want %q", got, want)
+	}
+}
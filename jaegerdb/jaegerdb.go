@@ -1,18 +1,19 @@
 package main
 
 import (
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/jyap808/jaeger/jaegeraudit"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
 	"golang.org/x/crypto/openpgp"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const jaegerTemplateExtension = ".jgrt"
@@ -21,38 +22,42 @@ const jaegerDBDescription = "JaegerDB - Jaeger database management program\n\nJa
 const jaegerQuote = "\"Stacker Pentecost: Haven't you heard Mr. Beckett? The world is coming to an end. So where would you rather die? Here? Or in a Jaeger!\" - Pacific Rim"
 const jaegerDBRecommendedUsage = "RECOMMENDED:\n    jaegerdb -j file.txt.jgrdb -a \"Field1\" -v \"Secret value\"\n\nThis will run JaegerDB with the default options and assume the following:\n    Keyring file: ~/.gnupg/jaeger_pubring.gpg"
 
-var debug debugging = false
-
-type debugging bool
-
-func (d debugging) Printf(format string, args ...interface{}) {
-	// From: https://groups.google.com/forum/#!msg/golang-nuts/gU7oQGoCkmg/BNIl-TqB-4wJ
-	if d {
-		log.Printf(format, args...)
-	}
-}
-
-type Data struct {
-	Properties []Property
-}
-
-type Property struct {
-	Name           string `json:"Name"`
-	EncryptedValue string `json:"EncryptedValue"`
-}
-
 func main() {
 	// Define flags
 	// TODO: View individual property and unencrypted value. 'get'
+	logFlags := jaegerlog.RegisterFlags()
 	var (
 		addKey         = flag.String("a", "", "Add property")
 		changeKey      = flag.String("c", "", "Change property")
-		debugFlag      = flag.Bool("d", false, "Enable Debug")
 		deleteKey      = flag.String("delete", "", "Delete property")
+		importFile     = flag.String("import", "", "Import properties from a .env, .json, .yaml or .properties file")
+		overwriteFlag  = flag.Bool("overwrite", false, "When importing, change properties that already exist")
+		skipExisting   = flag.Bool("skip-existing", false, "When importing, skip properties that already exist")
 		initializeFlag = flag.Bool("init", false, "Create an initial blank JSON GPG database file")
 		jsonGPGDB      = flag.String("j", "", "JSON GPG database file. eg. file.txt.jgrdb")
 		keyringFile    = flag.String("k", "", "Keyring file. Public key in ASCII armored format. eg. pubring.asc")
+		setKey         = flag.String("set", "", "Set property, adding it or changing it as needed")
 		value          = flag.String("v", "", "Value for property to use")
+		secretKeyring  = flag.String("s", "", "Secret keyring file, used to compare or show decrypted values. Changing a property to the value it already has leaves it as it is when the secret key is available. Private key in ASCII armored format. eg. secret.asc. Defaults to ~/.gnupg/jaeger_secring.gpg if it exists")
+		passphrase     = flag.String("p", "", "Passphrase for the secret keyring. If this is not set the passphrase will be blank or read from the environment variable PASSPHRASE.")
+		textconvFile   = flag.String("textconv", "", "Print the properties of a JSON GPG database file as sorted 'Name = value' lines for git diff. Values are keyed fingerprints unless -show-secrets is set, or fingerprints of the ciphertext without the secret key")
+		showSecrets    = flag.Bool("show-secrets", false, "Show decrypted values with -textconv instead of fingerprints")
+		mergeFlag      = flag.Bool("merge", false, "Git merge driver. Merge the JSON GPG database files given as arguments, the ancestor, ours and theirs (%O %A %B), by property into ours. Exits with a non-zero status on conflicts")
+		fmtFlag        = flag.Bool("fmt", false, "Rewrite JSON GPG database files, the arguments or -j, in canonical form: properties sorted by name, stable indentation and a trailing newline")
+		checkFlag      = flag.Bool("check", false, "With -fmt, list the files that are not in canonical form and exit with a non-zero status instead of rewriting them")
+		installGitFlag = flag.Bool("install-git", false, "Configure git in the current repository to diff JSON GPG database files with -textconv and merge them with -merge")
+		historyKey     = flag.String("history", "", "List the versions kept of a property with their time and, a fingerprint of the value")
+		historySize    = flag.Int("history-size", defaultHistorySize, "Number of previous values kept per property when it is changed")
+		rollbackKey    = flag.String("rollback", "", "Restore a previous version of a property, given with -to")
+		rollbackTo     = flag.Int("to", 0, "Version to restore with -rollback")
+		renameKey      = flag.String("rename", "", "Rename a property, keeping its encrypted value and versions. The new name follows the flags. eg. jaegerdb -rename Old New")
+		copyKey        = flag.String("copy", "", "Copy a property to a new property with the same encrypted value. The new name follows the flags. eg. jaegerdb -copy Source Destination")
+		rewriteFlag    = flag.Bool("rewrite-template", false, "With -rename, also rewrite the references to the property in the matching Template file. eg. file.txt.jgrt for file.txt.jgrdb")
+		auditFlag      = flag.Bool("audit", false, "Display the audit log of the JSON GPG database file and verify its hash chain and signatures. Exits with a non-zero status on problems")
+		requireSigned  = flag.Bool("require-signed", true, "With -audit, report unsigned entries and entries signed by a key that isn't in the keyrings as problems")
+		signKey        = flag.String("sign-key", "", "Your personal private key, in ASCII armored format, that signs the audit log entries of your changes. It is not the secret key of the store, which everyone who can decrypt the store shares. Defaults to ~/.gnupg/secring.gpg if it exists, otherwise entries are unsigned")
+		signPassphrase = flag.String("sign-passphrase", "", "Passphrase for -sign-key. If this is not set the passphrase will be blank or read from the environment variable SIGN_PASSPHRASE.")
+		mergeAuditFlag = flag.Bool("merge-audit", false, "Git merge driver for audit logs. Append the entries of theirs that ours doesn't have to ours, the files given as arguments (%O %A %B), followed by a merge entry")
 	)
 
 	flag.Usage = func() {
@@ -63,8 +68,132 @@ func main() {
 
 	flag.Parse()
 
-	if *debugFlag {
-		debug = true
+	if err := logFlags.Setup(); err != nil {
+		flag.Usage()
+		log.Fatalf("\n\n%s", err)
+	}
+
+	if *passphrase == "" {
+		*passphrase = os.Getenv("PASSPHRASE")
+	}
+	if *signPassphrase == "" {
+		*signPassphrase = os.Getenv("SIGN_PASSPHRASE")
+	}
+
+	// Read the secret key only for the operations that use it, and only once
+	var secretEntitylist openpgp.EntityList
+	secretLoaded := false
+	secretKeys := func() openpgp.EntityList {
+		if !secretLoaded {
+			secretEntitylist = loadOptionalSecretKeyRing(secretKeyring, passphrase)
+			secretLoaded = true
+		}
+		return secretEntitylist
+	}
+	var signingKey *openpgp.Entity
+	signerLoaded := false
+	signer := func() *openpgp.Entity {
+		if !signerLoaded {
+			var err error
+			if signingKey, err = jaegeraudit.Signer(*signKey, *signPassphrase); err != nil {
+				log.Fatal(err)
+			}
+			signerLoaded = true
+		}
+		return signingKey
+	}
+
+	if *textconvFile != "" {
+		// Run by git diff, so a missing or unusable secret key still gives a diff of property names
+		output, err := textconvJaegerDB(*textconvFile, secretKeys(), *showSecrets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(output)
+		os.Exit(0)
+	}
+
+	if *mergeFlag {
+		if flag.NArg() != 3 {
+			flag.Usage()
+			log.Fatalf("\n\nError: -merge needs the ancestor, ours and theirs. eg. jaegerdb -merge %%O %%A %%B")
+		}
+		summary, err := mergeJaegerDB(flag.Arg(0), flag.Arg(1), flag.Arg(2), secretKeys())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, conflict := range summary.Conflicts {
+			fmt.Fprintln(os.Stderr, "CONFLICT:", conflict)
+		}
+		fmt.Fprintln(os.Stderr, summary)
+		if len(summary.Conflicts) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *mergeAuditFlag {
+		if flag.NArg() != 3 {
+			flag.Usage()
+			log.Fatalf("\n\nError: -merge-audit needs the ancestor, ours and theirs. eg. jaegerdb -merge-audit %%O %%A %%B")
+		}
+		added, err := jaegeraudit.Merge(flag.Arg(1), flag.Arg(2), signer())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Merged %d audit log entries\n", added)
+		os.Exit(0)
+	}
+
+	if *installGitFlag {
+		// git appends the file name to the textconv command, so it must end with -textconv
+		command := "jaegerdb"
+		if *secretKeyring != "" {
+			path, err := filepath.Abs(*secretKeyring)
+			if err != nil {
+				log.Fatal(err)
+			}
+			command += " -s " + shellQuote(path)
+		}
+		if *showSecrets {
+			command += " -show-secrets"
+		}
+		if err := installGit(command); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if *fmtFlag {
+		files := flag.Args()
+		if len(files) == 0 {
+			if *jsonGPGDB == "" {
+				assumedJaegerDB, err := checkExistsJaegerDB()
+				if err != nil {
+					flag.Usage()
+					log.Fatalf("\n\nError: %s", err)
+				}
+				*jsonGPGDB = assumedJaegerDB
+			}
+			files = []string{*jsonGPGDB}
+		}
+		unformatted := 0
+		for _, file := range files {
+			changed, err := formatJaegerDB(file, *checkFlag)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if changed && *checkFlag {
+				fmt.Println("Not in canonical form:", file)
+				unformatted++
+			} else if changed {
+				fmt.Println("Formatted and wrote to file:", file)
+			}
+		}
+		if unformatted > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
 	if *jsonGPGDB == "" {
@@ -76,6 +205,105 @@ func main() {
 		*jsonGPGDB = assumedJaegerDB
 	}
 
+	if *auditFlag {
+		summary, err := jaegeraudit.Verify(*jsonGPGDB, loadAuditKeyRing(keyringFile, secretKeys(), signer()), *requireSigned, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, problem := range summary.Problems {
+			fmt.Println(problem)
+		}
+		fmt.Println(summary)
+		if len(summary.Problems) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Every change is recorded in the audit log. The store is kept as it was before the operation, so a change
+	// is undone when it can't be recorded.
+	snapshot, err := jaegeraudit.TakeSnapshot(*jsonGPGDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	audit := func(operation string, properties ...string) {
+		if err := jaegeraudit.AppendOrRestore(*jsonGPGDB, operation, properties, signer(), snapshot); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *historyKey != "" {
+		if err := historyJaegerDB(*historyKey, *jsonGPGDB, secretKeys(), os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if *rollbackKey != "" {
+		if *rollbackTo < 1 {
+			flag.Usage()
+			log.Fatalf("\n\nError: No version for rollback operation specified. Use -to")
+		}
+		if err := rollbackJaegerDB(*rollbackKey, *rollbackTo, *jsonGPGDB, *historySize); err != nil {
+			log.Fatal(err)
+		}
+		audit("rollback", *rollbackKey)
+		fmt.Printf("Rolled back property %s to version %d and wrote to file: %s\n", *rollbackKey, *rollbackTo, *jsonGPGDB)
+		os.Exit(0)
+	}
+
+	if *renameKey != "" || *copyKey != "" {
+		source, operation := *renameKey, "rename"
+		if *copyKey != "" {
+			source, operation = *copyKey, "copy"
+		}
+		if *renameKey != "" && *copyKey != "" {
+			flag.Usage()
+			log.Fatalf("\n\nError: -rename and -copy cannot be used together")
+		}
+		if flag.NArg() != 1 {
+			flag.Usage()
+			log.Fatalf("\n\nError: No new property name specified. The new name must follow all flags. eg. jaegerdb -j file.txt.jgrdb -%s %s NewName", operation, source)
+		}
+		destination := flag.Arg(0)
+		if *rewriteFlag && operation != "rename" {
+			flag.Usage()
+			log.Fatalf("\n\nError: -rewrite-template can only be used with -rename")
+		}
+
+		inputTemplate := ""
+		snapshots := []jaegeraudit.Snapshot{snapshot}
+		if *rewriteFlag {
+			inputTemplate = matchingTemplate(*jsonGPGDB)
+			templateSnapshot, err := jaegeraudit.TakeSnapshot(inputTemplate)
+			if err != nil {
+				log.Fatal(err)
+			}
+			snapshots = append(snapshots, templateSnapshot)
+		}
+
+		references, err := renameJaegerDB(source, destination, *jsonGPGDB, operation == "copy", inputTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := jaegeraudit.AppendOrRestore(*jsonGPGDB, operation, []string{source + " -> " + destination}, signer(), snapshots...); err != nil {
+			log.Fatal(err)
+		}
+		if operation == "copy" {
+			fmt.Println("Copied property and wrote to file:", *jsonGPGDB)
+		} else {
+			fmt.Println("Renamed property and wrote to file:", *jsonGPGDB)
+		}
+		if *rewriteFlag {
+			if references == 0 {
+				fmt.Println("No references to rewrite in:", inputTemplate)
+			} else {
+				fmt.Printf("Rewrote %d references and wrote to file: %s\n", references, inputTemplate)
+			}
+		}
+		os.Exit(0)
+	}
+
 	if *initializeFlag {
 		err := initializeJSONGPGDB(jsonGPGDB)
 		if err != nil {
@@ -91,20 +319,22 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		} else {
+			audit("delete", *deleteKey)
 			fmt.Println("Deleted property and wrote to file:", *jsonGPGDB)
 			os.Exit(0)
 		}
 	}
 
-	var entitylist openpgp.EntityList
-
-	if *keyringFile == "" {
-		_, entitylist = processPublicKeyRing()
-	} else {
-		_, entitylist = processArmoredKeyRingFile(keyringFile)
+	entitylist, err := jaegerstore.PublicKeyRing(*keyringFile)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	if *addKey != "" {
+		if err := checkPropertyName(*addKey); err != nil {
+			flag.Usage()
+			log.Fatal(err)
+		}
 		if *value == "" {
 			flag.Usage()
 			log.Fatalf("\n\nError: No value for add key operation specified")
@@ -113,26 +343,65 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		} else {
+			audit("add", *addKey)
 			fmt.Println("Added property and wrote to file:", *jsonGPGDB)
 			os.Exit(0)
 		}
 	}
 
-	if *changeKey != "" {
+	if *importFile != "" {
+		policy := importPolicyError
+		if *overwriteFlag && *skipExisting {
+			flag.Usage()
+			log.Fatalf("\n\nError: -overwrite and -skip-existing cannot be used together")
+		} else if *overwriteFlag {
+			policy = importPolicyOverwrite
+		} else if *skipExisting {
+			policy = importPolicySkip
+		}
+		summary, err := importJaegerDB(importFile, jsonGPGDB, policy, entitylist, secretKeys(), *historySize)
+		if err != nil {
+			log.Fatal(err)
+		} else {
+			audit("add", summary.Added...)
+			audit("change", summary.Changed...)
+			fmt.Println(summary)
+			fmt.Println("Imported properties and wrote to file:", *jsonGPGDB)
+			os.Exit(0)
+		}
+	}
+
+	if *changeKey != "" || *setKey != "" {
+		key, add := changeKey, false
+		if *setKey != "" {
+			key, add = setKey, true
+			if err := checkPropertyName(*setKey); err != nil {
+				flag.Usage()
+				log.Fatal(err)
+			}
+		}
 		if *value == "" {
 			flag.Usage()
 			log.Fatalf("\n\nError: No value for change key operation specified")
 		}
-		err := changeKeyJaegerDB(changeKey, value, jsonGPGDB, entitylist)
+		result, err := setKeyJaegerDB(key, value, jsonGPGDB, entitylist, secretKeys(), add, *historySize)
 		if err != nil {
 			log.Fatal(err)
-		} else {
+		}
+		switch result {
+		case propertyAdded:
+			audit("add", *key)
+			fmt.Println("Added property and wrote to file:", *jsonGPGDB)
+		case propertyChanged:
+			audit("change", *key)
 			fmt.Println("Changed property and wrote to file:", *jsonGPGDB)
-			os.Exit(0)
+		default:
+			fmt.Println("Property unchanged:", *key)
 		}
+		os.Exit(0)
 	}
 
-	if *deleteKey == "" && *addKey == "" && *changeKey == "" {
+	if *deleteKey == "" && *addKey == "" && *changeKey == "" && *setKey == "" && *importFile == "" && *rollbackKey == "" {
 		log.Fatalf("\n\nError: No JSON GPG database operations specified")
 	}
 
@@ -173,124 +442,65 @@ func checkExistsJaegerDB() (string, error) {
 }
 
 func initializeJSONGPGDB(jsonGPGDB *string) error {
+	start := time.Now()
+
 	if _, err := os.Stat(*jsonGPGDB); err == nil {
 		return fmt.Errorf("ERR: File already exists: %v", *jsonGPGDB)
 	}
 
-	var newP []Property
+	var newP []jaegerstore.Property
 
-	newData := Data{newP}
+	newData := jaegerstore.Data{Properties: newP}
 
-	bytes, err := json.MarshalIndent(newData, "", "    ")
+	bytes, err := jaegerstore.Marshal(newData)
 	if err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
 
-	debug.Printf("b: %v", string(bytes))
+	jaegerlog.Debug("marshalled JSON GPG database", jaegerlog.Store(*jsonGPGDB), "bytes", len(bytes))
 
 	// Writing file
 	// To handle large files, use a file buffer: http://stackoverflow.com/a/9739903/603745
 	if err := ioutil.WriteFile(*jsonGPGDB, bytes, 0644); err != nil {
-		return fmt.Errorf("error:", err)
-	}
-
-	return nil
-}
-
-func encodeBase64EncryptedMessage(s string, entitylist openpgp.EntityList) string {
-	// Encrypt message using public key and then encode with base64
-	debug.Printf("entitylist: #%v", entitylist)
-	buf := new(bytes.Buffer)
-	w, err := openpgp.Encrypt(buf, entitylist, nil, nil, nil)
-	if err != nil {
-		log.Fatalln("ERR: Error encrypting message - ", err)
+		return fmt.Errorf("error: %v", err)
 	}
 
-	_, err = w.Write([]byte(s))
-	err = w.Close()
+	jaegerlog.Info("initialized store", jaegerlog.Operation("init"), jaegerlog.Store(*jsonGPGDB), jaegerlog.Duration(start))
 
-	// Output as base64 encoded string
-	bytes, err := ioutil.ReadAll(buf)
-	str := base64.StdEncoding.EncodeToString(bytes)
-
-	debug.Printf("Public key encrypted message (base64 encoded): %v", str)
-
-	return str
-}
-
-func processPublicKeyRing() (entity *openpgp.Entity, entitylist openpgp.EntityList) {
-	// TODO: Handle a specified recipient
-	// Get default public keyring location
-	usr, err := user.Current()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	jaegerPublicKeyRing := fmt.Sprintf("%v/.gnupg/jaeger_pubring.gpg", usr.HomeDir)
-	publicKeyRing := ""
-
-	if _, err := os.Stat(jaegerPublicKeyRing); err == nil {
-		publicKeyRing = jaegerPublicKeyRing
-	} else {
-		publicKeyRing = fmt.Sprintf("%v/.gnupg/pubring.gpg", usr.HomeDir)
-	}
-
-	debug.Printf("publicKeyRing file:", publicKeyRing)
-	publicKeyRingBuffer, err := os.Open(publicKeyRing)
-	if err != nil {
-		panic(err)
-	}
-	entitylist, err = openpgp.ReadKeyRing(publicKeyRingBuffer)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	entity = entitylist[0]
-	debug.Printf("Public key default keyring:", entity.Identities)
-
-	return entity, entitylist
-}
-
-func processArmoredKeyRingFile(keyringFile *string) (entity *openpgp.Entity, entitylist openpgp.EntityList) {
-	keyringFileBuffer, err := os.Open(*keyringFile)
-	if err != nil {
-		log.Fatalln("ERROR: Unable to read keyring file")
-	}
-	entitylist, err = openpgp.ReadArmoredKeyRing(keyringFileBuffer)
-	if err != nil {
-		log.Fatal(err)
-	}
-	entity = entitylist[0]
-	debug.Printf("Public key from ASCII armored string:", entity.Identities)
-
-	return entity, entitylist
+	return nil
 }
 
 func addKeyJaegerDB(key *string, value *string, jsonGPGDB *string, entitylist openpgp.EntityList) error {
+	start := time.Now()
+
 	// json handling
 	jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB)
 	if err != nil {
 		return fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
 	}
 
-	var j Data
+	var j jaegerstore.Data
 	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
-	debug.Printf("json unmarshal: %v", j)
+	jaegerlog.Debug("json unmarshal", jaegerlog.Store(*jsonGPGDB), "properties", len(j.Properties))
 
 	found := false
 
-	var newP []Property
+	var newP []jaegerstore.Property
 
-	p := Property{Name: *key, EncryptedValue: encodeBase64EncryptedMessage(*value, entitylist)}
+	encryptedValue, err := jaegerstore.Encrypt(*value, entitylist)
+	if err != nil {
+		return err
+	}
+	p := jaegerstore.Property{Name: *key}
+	p.SetValue(encryptedValue, 0)
 
 	// Search
 	for i := range j.Properties {
 		property := &j.Properties[i]
-		debug.Printf("i: %v, Name: %#v, EncryptedValue: %#v\n", i, property.Name, property.EncryptedValue)
+		jaegerlog.Debug("property", jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(property.Name), "index", i)
 		if property.Name == *key {
-			j.Properties[i] = p
 			found = true
 			break
 		}
@@ -302,77 +512,119 @@ func addKeyJaegerDB(key *string, value *string, jsonGPGDB *string, entitylist op
 
 	newP = append(j.Properties, p)
 
-	debug.Printf("new properties: %v", newP)
+	jaegerlog.Debug("new properties", jaegerlog.Store(*jsonGPGDB), "properties", len(newP))
 
-	newData := Data{newP}
+	newData := jaegerstore.Data{Properties: newP}
 
-	bytes, err := json.MarshalIndent(newData, "", "    ")
+	bytes, err := jaegerstore.Marshal(newData)
 	if err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
 
-	debug.Printf("b: %v", string(bytes))
+	jaegerlog.Debug("marshalled JSON GPG database", jaegerlog.Store(*jsonGPGDB), "bytes", len(bytes))
 
 	// Writing file
 	// To handle large files, use a file buffer: http://stackoverflow.com/a/9739903/603745
 	if err := ioutil.WriteFile(*jsonGPGDB, bytes, 0644); err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
 
+	jaegerlog.Info("added property", jaegerlog.Operation("add"), jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(*key), jaegerlog.Duration(start))
+
 	return nil
 }
 
-func changeKeyJaegerDB(key *string, value *string, jsonGPGDB *string, entitylist openpgp.EntityList) error {
+// Results of setting a property
+const (
+	propertyAdded     = "added"
+	propertyChanged   = "changed"
+	propertyUnchanged = "unchanged"
+)
+
+func setKeyJaegerDB(key *string, value *string, jsonGPGDB *string, entitylist openpgp.EntityList, secretEntitylist openpgp.EntityList, add bool, historySize int) (string, error) {
+	// Change a property, or add it if add is set. When the secret key is available and the property already has
+	// the value, the file is left as it is.
+	start := time.Now()
+
 	// json handling
 	jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB)
 	if err != nil {
-		return fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+		return "", fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
 	}
 
-	var j Data
+	var j jaegerstore.Data
 	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
-		return fmt.Errorf("error:", err)
+		return "", fmt.Errorf("error: %v", err)
 	}
-	debug.Printf("json unmarshal: %v", j)
-
-	found := false
+	jaegerlog.Debug("json unmarshal", jaegerlog.Store(*jsonGPGDB), "properties", len(j.Properties))
 
-	// New property to replace the old
-	p := Property{Name: *key, EncryptedValue: encodeBase64EncryptedMessage(*value, entitylist)}
+	result := propertyAdded
 
 	// Search and replace
 	for i := range j.Properties {
 		property := &j.Properties[i]
-		debug.Printf("i: %v, Name: %#v, EncryptedValue: %#v\n", i, property.Name, property.EncryptedValue)
+		jaegerlog.Debug("property", jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(property.Name), "index", i)
 		if property.Name == *key {
-			j.Properties[i] = p
-			found = true
+			if unchangedValue(*property, *value, secretEntitylist) {
+				jaegerlog.Info("property unchanged", jaegerlog.Operation("change"), jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(*key), jaegerlog.Duration(start))
+				return propertyUnchanged, nil
+			}
+			encryptedValue, err := jaegerstore.Encrypt(*value, entitylist)
+			if err != nil {
+				return "", err
+			}
+			property.SetValue(encryptedValue, historySize)
+			result = propertyChanged
 			break
 		}
 	}
 
-	if !found {
-		return fmt.Errorf("\n\nError: Property '%s' not found.", *key)
+	if result == propertyAdded {
+		if !add {
+			return "", fmt.Errorf("\n\nError: Property '%s' not found.", *key)
+		}
+		encryptedValue, err := jaegerstore.Encrypt(*value, entitylist)
+		if err != nil {
+			return "", err
+		}
+		p := jaegerstore.Property{Name: *key}
+		p.SetValue(encryptedValue, historySize)
+		j.Properties = append(j.Properties, p)
 	}
 
-	bytes, err := json.MarshalIndent(j, "", "    ")
+	bytes, err := jaegerstore.Marshal(j)
 	if err != nil {
-		return fmt.Errorf("error:", err)
+		return "", fmt.Errorf("error: %v", err)
 	}
 
-	debug.Printf("b: %v", string(bytes))
+	jaegerlog.Debug("marshalled JSON GPG database", jaegerlog.Store(*jsonGPGDB), "bytes", len(bytes))
 
 	// Writing file
 	// To handle large files, use a file buffer: http://stackoverflow.com/a/9739903/603745
 	if err := ioutil.WriteFile(*jsonGPGDB, bytes, 0644); err != nil {
-		return fmt.Errorf("error:", err)
+		return "", fmt.Errorf("error: %v", err)
 	}
 
-	return nil
+	jaegerlog.Info(result+" property", jaegerlog.Operation("change"), jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(*key), jaegerlog.Duration(start))
+
+	return result, nil
+}
+
+func unchangedValue(property jaegerstore.Property, value string, secretEntitylist openpgp.EntityList) bool {
+	// Encrypting gives different ciphertext every time, so an unchanged value can only be recognized decrypted
+	if secretEntitylist == nil {
+		return false
+	}
+	existing, err := jaegerstore.Decrypt(property.EncryptedValue, secretEntitylist)
+	if err != nil {
+		jaegerlog.Debug("unable to decrypt existing value", jaegerlog.Property(property.Name), "error", err)
+		return false
+	}
+	return existing == value
 }
 
 func deleteKeyJaegerDB(key *string, jsonGPGDB *string) error {
-	debug.Printf("deleteKeyJaegerDB key: %v", *key)
+	start := time.Now()
 
 	// json handling
 	jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB)
@@ -380,18 +632,18 @@ func deleteKeyJaegerDB(key *string, jsonGPGDB *string) error {
 		return fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
 	}
 
-	var j Data
+	var j jaegerstore.Data
 	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
-	debug.Printf("json unmarshal: %v", j)
+	jaegerlog.Debug("json unmarshal", jaegerlog.Store(*jsonGPGDB), "properties", len(j.Properties))
 
-	var newP []Property
+	var newP []jaegerstore.Property
 	found := false
 
 	for i := range j.Properties {
 		property := &j.Properties[i]
-		debug.Printf("i: %v, Name: %#v, EncryptedValue: %#v\n", i, property.Name, property.EncryptedValue)
+		jaegerlog.Debug("property", jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(property.Name), "index", i)
 		if property.Name == *key {
 			// https://code.google.com/p/go-wiki/wiki/SliceTricks
 			newP = j.Properties[:i+copy(j.Properties[i:], j.Properties[i+1:])]
@@ -404,22 +656,24 @@ func deleteKeyJaegerDB(key *string, jsonGPGDB *string) error {
 		return fmt.Errorf("\n\nError: Property '%s' not found.", *key)
 	}
 
-	debug.Printf("new properties: %v", newP)
+	jaegerlog.Debug("new properties", jaegerlog.Store(*jsonGPGDB), "properties", len(newP))
 
-	newData := Data{newP}
+	newData := jaegerstore.Data{Properties: newP}
 
-	bytes, err := json.MarshalIndent(newData, "", "    ")
+	bytes, err := jaegerstore.Marshal(newData)
 	if err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
 
-	debug.Printf("b: %v", string(bytes))
+	jaegerlog.Debug("marshalled JSON GPG database", jaegerlog.Store(*jsonGPGDB), "bytes", len(bytes))
 
 	// Writing file
 	// To handle large files, use a file buffer: http://stackoverflow.com/a/9739903/603745
 	if err := ioutil.WriteFile(*jsonGPGDB, bytes, 0644); err != nil {
-		return fmt.Errorf("error:", err)
+		return fmt.Errorf("error: %v", err)
 	}
 
+	jaegerlog.Info("deleted property", jaegerlog.Operation("delete"), jaegerlog.Store(*jsonGPGDB), jaegerlog.Property(*key), jaegerlog.Duration(start))
+
 	return nil
 }
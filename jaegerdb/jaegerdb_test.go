@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetKeyJaegerDB(t *testing.T) {
+	entitylist := testEntityList(t)
+	otherEntitylist := testEntityList(t)
+	jsonGPGDB := filepath.Join(t.TempDir(), "app.jgrdb")
+
+	tests := []struct {
+		name      string
+		existing  map[string]string
+		key       string
+		value     string
+		secretKey string // Secret key given to recognize an unchanged value: store, other or none
+		add       bool
+		want      string
+		wantErr   bool
+	}{
+		{"unchanged value", map[string]string{"Password": "hunter2"}, "Password", "hunter2", "store", false, propertyUnchanged, false},
+		{"same value without the secret key", map[string]string{"Password": "hunter2"}, "Password", "hunter2", "none", false, propertyChanged, false},
+		{"same value with another secret key", map[string]string{"Password": "hunter2"}, "Password", "hunter2", "other", false, propertyChanged, false},
+		{"changed value", map[string]string{"Password": "hunter2"}, "Password", "correct horse", "store", false, propertyChanged, false},
+		{"added property", map[string]string{"Password": "hunter2"}, "Token", "t1", "store", true, propertyAdded, false},
+		{"unchanged value with -set", map[string]string{"Token": "t1"}, "Token", "t1", "store", true, propertyUnchanged, false},
+		{"missing property", map[string]string{"Password": "hunter2"}, "Missing", "x", "store", false, "", true},
+	}
+	for _, test := range tests {
+		writeTestStore(t, jsonGPGDB, test.existing, entitylist)
+		before, err := ioutil.ReadFile(jsonGPGDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secretEntitylist := map[string]openpgp.EntityList{"store": entitylist, "other": otherEntitylist}[test.secretKey]
+		got, err := setKeyJaegerDB(&test.key, &test.value, &jsonGPGDB, entitylist, secretEntitylist, test.add, defaultHistorySize)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: setKeyJaegerDB() = %s, want %s", test.name, got, test.want)
+		}
+
+		after, err := ioutil.ReadFile(jsonGPGDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unchanged := bytes.Equal(before, after); unchanged != (test.want == propertyUnchanged) {
+			t.Errorf("%s: file left as it was = %v, want %v", test.name, unchanged, test.want == propertyUnchanged)
+		}
+		if values := readTestStore(t, jsonGPGDB, entitylist); values[test.key] != test.value {
+			t.Errorf("%s: %s = %q, want %q", test.name, test.key, values[test.key], test.value)
+		}
+	}
+}
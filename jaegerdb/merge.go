@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"strings"
+)
+
+type mergeSummary struct {
+	Merged    int
+	Conflicts []string
+}
+
+func (s mergeSummary) String() string {
+	return fmt.Sprintf("Summary: %d properties merged, %d conflicts", s.Merged, len(s.Conflicts))
+}
+
+// mergeSide is one version of a JSON GPG database file, properties by name in file order
+type mergeSide struct {
+	names      []string
+	values     map[string]string
+	properties map[string]jaegerstore.Property
+}
+
+func readMergeSide(jsonGPGDB string) (mergeSide, error) {
+	side := mergeSide{values: make(map[string]string), properties: make(map[string]jaegerstore.Property)}
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return side, fmt.Errorf("ERROR: Unable to read JSON GPG DB file: %v", jsonGPGDB)
+	}
+	// git gives an empty ancestor when both sides added the file
+	if strings.TrimSpace(string(jsonGPGDBBuffer)) == "" {
+		return side, nil
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return side, fmt.Errorf("error: %v: %v", jsonGPGDB, err)
+	}
+	for _, property := range j.Properties {
+		if _, found := side.values[property.Name]; !found {
+			side.names = append(side.names, property.Name)
+			side.values[property.Name] = property.EncryptedValue
+			side.properties[property.Name] = property
+		}
+	}
+	return side, nil
+}
+
+func mergeJaegerDB(base string, ours string, theirs string, entitylist openpgp.EntityList) (mergeSummary, error) {
+	// Three way merge by property name for git, which passes the common ancestor, our version and their version.
+	// The result replaces our version. A property conflicts only when both sides changed it differently. Our value
+	// is kept then, or theirs when we deleted it, so nothing is lost.
+	var summary mergeSummary
+
+	o, err := readMergeSide(base)
+	if err != nil {
+		return summary, err
+	}
+	a, err := readMergeSide(ours)
+	if err != nil {
+		return summary, err
+	}
+	b, err := readMergeSide(theirs)
+	if err != nil {
+		return summary, err
+	}
+
+	// Encrypting the same value twice gives different ciphertext, so compare plaintext when it can be decrypted
+	same := func(x string, xFound bool, y string, yFound bool) bool {
+		if xFound != yFound {
+			return false
+		}
+		if !xFound || x == y || entitylist == nil {
+			return x == y
+		}
+		xValue, xErr := jaegerstore.Decrypt(x, entitylist)
+		yValue, yErr := jaegerstore.Decrypt(y, entitylist)
+		return xErr == nil && yErr == nil && xValue == yValue
+	}
+
+	// Every property on any side
+	var names []string
+	seen := make(map[string]bool)
+	for _, side := range []mergeSide{a, b, o} {
+		for _, name := range side.names {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	var merged jaegerstore.Data
+	for _, name := range names {
+		oValue, oFound := o.values[name]
+		aValue, aFound := a.values[name]
+		bValue, bFound := b.values[name]
+
+		// The whole property is taken from one side, with its version history
+		property, found := a.properties[name], aFound
+		switch {
+		case same(aValue, aFound, bValue, bFound):
+			// Same on both sides
+		case same(aValue, aFound, oValue, oFound):
+			// Only they changed it
+			property, found = b.properties[name], bFound
+		case same(bValue, bFound, oValue, oFound):
+			// Only we changed it
+		default:
+			switch {
+			case !aFound:
+				summary.Conflicts = append(summary.Conflicts, fmt.Sprintf("%s: deleted by us and changed by them", name))
+				property, found = b.properties[name], bFound
+			case !bFound:
+				summary.Conflicts = append(summary.Conflicts, fmt.Sprintf("%s: changed by us and deleted by them", name))
+			case !oFound:
+				summary.Conflicts = append(summary.Conflicts, fmt.Sprintf("%s: added with different values on both sides", name))
+			default:
+				summary.Conflicts = append(summary.Conflicts, fmt.Sprintf("%s: changed on both sides", name))
+			}
+		}
+		if found {
+			merged.Properties = append(merged.Properties, property)
+			summary.Merged++
+		}
+	}
+
+	bytes, err := jaegerstore.Marshal(merged)
+	if err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+	if err := ioutil.WriteFile(ours, bytes, 0644); err != nil {
+		return summary, fmt.Errorf("error: %v", err)
+	}
+	return summary, nil
+}
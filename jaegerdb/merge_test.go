@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestStore(t *testing.T, path string, values map[string]string, entitylist openpgp.EntityList) {
+	// A nil map writes an empty file, as git passes for the ancestor when both sides added the file
+	t.Helper()
+	var content []byte
+	if values != nil {
+		var j jaegerstore.Data
+		for name, value := range values {
+			p := jaegerstore.Property{Name: name}
+			p.SetValue(testEncrypt(t, value, entitylist), defaultHistorySize)
+			j.Properties = append(j.Properties, p)
+		}
+		var err error
+		if content, err = jaegerstore.Marshal(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestStore(t *testing.T, path string, entitylist openpgp.EntityList) map[string]string {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(content, &j); err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	for _, property := range j.Properties {
+		value, err := jaegerstore.Decrypt(property.EncryptedValue, entitylist)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[property.Name] = value
+	}
+	return values
+}
+
+func TestMergeJaegerDB(t *testing.T) {
+	entitylist := testEntityList(t)
+	tests := []struct {
+		name          string
+		base          map[string]string
+		ours          map[string]string
+		theirs        map[string]string
+		want          map[string]string
+		wantConflicts []string
+	}{
+		{
+			"unchanged",
+			map[string]string{"A": "1"},
+			map[string]string{"A": "1"},
+			map[string]string{"A": "1"},
+			map[string]string{"A": "1"},
+			nil,
+		},
+		{
+			"changed and added on different sides",
+			map[string]string{"A": "1", "B": "2"},
+			map[string]string{"A": "x", "B": "2"},
+			map[string]string{"A": "1", "B": "2", "C": "3"},
+			map[string]string{"A": "x", "B": "2", "C": "3"},
+			nil,
+		},
+		{
+			"changed by them",
+			map[string]string{"A": "1"},
+			map[string]string{"A": "1"},
+			map[string]string{"A": "y"},
+			map[string]string{"A": "y"},
+			nil,
+		},
+		{
+			"deleted by one side",
+			map[string]string{"A": "1", "B": "2"},
+			map[string]string{"B": "2"},
+			map[string]string{"A": "1", "B": "2"},
+			map[string]string{"B": "2"},
+			nil,
+		},
+		{
+			"same change on both sides",
+			map[string]string{"A": "1"},
+			map[string]string{"A": "x"},
+			map[string]string{"A": "x"},
+			map[string]string{"A": "x"},
+			nil,
+		},
+		{
+			"changed on both sides",
+			map[string]string{"A": "1"},
+			map[string]string{"A": "x"},
+			map[string]string{"A": "y"},
+			map[string]string{"A": "x"},
+			[]string{"A: changed on both sides"},
+		},
+		{
+			"deleted by us and changed by them",
+			map[string]string{"A": "1"},
+			map[string]string{},
+			map[string]string{"A": "y"},
+			map[string]string{"A": "y"},
+			[]string{"A: deleted by us and changed by them"},
+		},
+		{
+			"changed by us and deleted by them",
+			map[string]string{"A": "1"},
+			map[string]string{"A": "x"},
+			map[string]string{},
+			map[string]string{"A": "x"},
+			[]string{"A: changed by us and deleted by them"},
+		},
+		{
+			"added on both sides without an ancestor",
+			nil,
+			map[string]string{"A": "x", "B": "2"},
+			map[string]string{"A": "y", "B": "2"},
+			map[string]string{"A": "x", "B": "2"},
+			[]string{"A: added with different values on both sides"},
+		},
+	}
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.jgrdb")
+	ours := filepath.Join(dir, "ours.jgrdb")
+	theirs := filepath.Join(dir, "theirs.jgrdb")
+	for _, test := range tests {
+		writeTestStore(t, base, test.base, entitylist)
+		writeTestStore(t, ours, test.ours, entitylist)
+		writeTestStore(t, theirs, test.theirs, entitylist)
+
+		summary, err := mergeJaegerDB(base, ours, theirs, entitylist)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got := readTestStore(t, ours, entitylist); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: merged %q, want %q", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(summary.Conflicts, test.wantConflicts) {
+			t.Errorf("%s: conflicts %q, want %q", test.name, summary.Conflicts, test.wantConflicts)
+		}
+		if summary.Merged != len(test.want) {
+			t.Errorf("%s: %d properties merged, want %d", test.name, summary.Merged, len(test.want))
+		}
+	}
+}
+
+func TestMergeJaegerDBWithoutSecretKey(t *testing.T) {
+	// Without the secret key values are compared by ciphertext, so a value encrypted again on both sides conflicts
+	entitylist := testEntityList(t)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.jgrdb")
+	ours := filepath.Join(dir, "ours.jgrdb")
+	theirs := filepath.Join(dir, "theirs.jgrdb")
+	writeTestStore(t, base, map[string]string{"A": "1"}, entitylist)
+	writeTestStore(t, ours, map[string]string{"A": "x"}, entitylist)
+	writeTestStore(t, theirs, map[string]string{"A": "x"}, entitylist)
+
+	summary, err := mergeJaegerDB(base, ours, theirs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Conflicts) != 1 || !strings.HasPrefix(summary.Conflicts[0], "A:") {
+		t.Errorf("conflicts %q, want one for A", summary.Conflicts)
+	}
+}
+
+func TestMergeJaegerDBKeepsHistory(t *testing.T) {
+	entitylist := testEntityList(t)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.jgrdb")
+	ours := filepath.Join(dir, "ours.jgrdb")
+	theirs := filepath.Join(dir, "theirs.jgrdb")
+	writeTestStore(t, base, map[string]string{"A": "1"}, entitylist)
+	writeTestStore(t, ours, map[string]string{"A": "1"}, entitylist)
+
+	// They changed A, which keeps the previous value as version 1
+	content, err := ioutil.ReadFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(content, &j); err != nil {
+		t.Fatal(err)
+	}
+	j.Properties[0].SetValue(testEncrypt(t, "2", entitylist), defaultHistorySize)
+	if content, err = jaegerstore.Marshal(j); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(theirs, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mergeJaegerDB(base, ours, theirs, entitylist); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := ioutil.ReadFile(ours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(merged) != string(content) {
+		t.Errorf("merged store differs from theirs:\n%s\nwant:\n%s", merged, content)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegeraudit"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+)
+
+func renameJaegerDB(oldName string, newName string, jsonGPGDB string, copy bool, inputTemplate string) (int, error) {
+	// Rename a property, keeping its ciphertext and versions, or with copy add a property with the same
+	// ciphertext. Nothing is decrypted. With an inputTemplate its references are rewritten too: the Template is
+	// written first and put back if the JSON GPG database file can't be written, so the two never disagree.
+	// Returns the number of references rewritten.
+	start := time.Now()
+	operation := "rename"
+	if copy {
+		operation = "copy"
+	}
+	if err := checkPropertyName(newName); err != nil {
+		return 0, err
+	}
+
+	jsonGPGDBBuffer, err := ioutil.ReadFile(jsonGPGDB)
+	if err != nil {
+		return 0, fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+	}
+	var j jaegerstore.Data
+	if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+		return 0, fmt.Errorf("error: %v", err)
+	}
+
+	property := j.Find(oldName)
+	if property == nil {
+		return 0, fmt.Errorf("\n\nError: Property '%s' not found.", oldName)
+	}
+	if j.Find(newName) != nil {
+		return 0, fmt.Errorf("\n\nError: Property '%s' already exists.", newName)
+	}
+
+	// Everything is checked and prepared before either file is written
+	var rewritten []byte
+	var references int
+	if inputTemplate != "" {
+		if rewritten, references, err = rewriteTemplateReferences(inputTemplate, oldName, newName); err != nil {
+			return 0, err
+		}
+	}
+
+	if copy {
+		p := jaegerstore.Property{Name: newName}
+		p.SetValue(property.EncryptedValue, 0)
+		j.Properties = append(j.Properties, p)
+	} else {
+		property.Name = newName
+	}
+	bytes, err := jaegerstore.Marshal(j)
+	if err != nil {
+		return 0, fmt.Errorf("error: %v", err)
+	}
+
+	var templateSnapshot jaegeraudit.Snapshot
+	if references > 0 {
+		if templateSnapshot, err = jaegeraudit.TakeSnapshot(inputTemplate); err != nil {
+			return 0, err
+		}
+		if err := writeRewrittenTemplate(inputTemplate, rewritten); err != nil {
+			return 0, err
+		}
+	}
+	if err := ioutil.WriteFile(jsonGPGDB, bytes, 0644); err != nil {
+		if references > 0 {
+			if restoreErr := templateSnapshot.Restore(); restoreErr != nil {
+				return 0, fmt.Errorf("error: %v\n\nError: Unable to undo the rewrite of %v: %v", err, inputTemplate, restoreErr)
+			}
+		}
+		return 0, fmt.Errorf("error: %v", err)
+	}
+
+	jaegerlog.Info(operation+" property", jaegerlog.Operation(operation), jaegerlog.Store(jsonGPGDB), jaegerlog.Property(oldName),
+		"to", newName, "references", references, jaegerlog.Duration(start))
+	return references, nil
+}
+
+func checkPropertyName(name string) error {
+	// Names are written as they are to "Name = value" lines by -textconv and to the audit log, so they must be
+	// one line without surrounding space
+	if name == "" {
+		return fmt.Errorf("\n\nError: No property name specified")
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("\n\nError: Property name '%s' starts or ends with a space", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("\n\nError: Property name %q holds a control character", name)
+		}
+	}
+	return nil
+}
+
+func matchingTemplate(jsonGPGDB string) string {
+	return strings.TrimSuffix(jsonGPGDB, jaegerDBExtension) + jaegerTemplateExtension
+}
+
+func rewriteTemplateReferences(inputTemplate string, oldName string, newName string) ([]byte, int, error) {
+	// Replace the references to a property in a Template: {{.Old}}, {{$.Old}} and {{index . "Old"}}. The rest
+	// of the file is left byte for byte as it is.
+	content, err := ioutil.ReadFile(inputTemplate)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ERROR: Unable to read Template file: %v", err)
+	}
+	t, err := template.New(inputTemplate).Funcs(jaegertemplate.FuncMap).Parse(string(content))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Source spans to replace, by position
+	type span struct {
+		pos  int
+		text string
+		with string
+	}
+	spans := make(map[int]span)
+	var problems []string
+	add := func(name string, pos int, text string, with string) {
+		if name != oldName {
+			return
+		}
+		if with == "" {
+			problems = append(problems, fmt.Sprintf("%s:%d: '%s' is not a valid field name, use {{index . %q}}", inputTemplate, lineNumber(content, pos), newName, newName))
+			return
+		}
+		if pos < 0 || pos+len(text) > len(content) || string(content[pos:pos+len(text)]) != text {
+			problems = append(problems, fmt.Sprintf("%s:%d: unable to find the reference to rewrite", inputTemplate, lineNumber(content, pos)))
+			return
+		}
+		spans[pos] = span{pos: pos, text: text, with: with}
+	}
+	field := ""
+	if isIdentifier(newName) {
+		field = "." + newName
+	}
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+		jaegertemplate.WalkReferences(tmpl.Tree.Root, func(name string, node parse.Node) {
+			switch node := node.(type) {
+			case *parse.FieldNode:
+				add(name, int(node.Pos), "."+name, field)
+			case *parse.VariableNode:
+				// $.Old, positioned at the field or at $
+				pos := int(node.Pos)
+				if pos < len(content) && content[pos] == '$' {
+					pos++
+				}
+				add(name, pos, "."+name, field)
+			case *parse.StringNode:
+				add(name, int(node.Pos), node.Quoted, strconv.Quote(newName))
+			}
+		})
+	}
+	if len(problems) > 0 {
+		return nil, 0, fmt.Errorf("ERROR: Unable to rewrite %s, nothing was changed:\n%s", inputTemplate, strings.Join(problems, "\n"))
+	}
+
+	positions := make([]int, 0, len(spans))
+	for pos := range spans {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+
+	var rewritten []byte
+	last := 0
+	for _, pos := range positions {
+		s := spans[pos]
+		rewritten = append(rewritten, content[last:s.pos]...)
+		rewritten = append(rewritten, s.with...)
+		last = s.pos + len(s.text)
+	}
+	rewritten = append(rewritten, content[last:]...)
+	return rewritten, len(spans), nil
+}
+
+func isIdentifier(name string) bool {
+	// Names that can be used as a field, {{.Name}}
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func lineNumber(content []byte, pos int) int {
+	if pos > len(content) {
+		pos = len(content)
+	}
+	return strings.Count(string(content[:pos]), "\n") + 1
+}
+
+func writeRewrittenTemplate(inputTemplate string, content []byte) error {
+	info, err := os.Stat(inputTemplate)
+	if err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	if err := ioutil.WriteFile(inputTemplate, content, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRewriteTemplateReferences(t *testing.T) {
+	tests := []struct {
+		name           string
+		template       string
+		newName        string
+		want           string
+		wantReferences int
+		wantErr        bool
+	}{
+		{
+			"field and $",
+			"a = {{.Old}}\n{{range .List}}{{.Old}} {{$.Old}}{{end}}\n",
+			"New",
+			"a = {{.New}}\n{{range .List}}{{.Old}} {{$.New}}{{end}}\n",
+			2,
+			false,
+		},
+		{
+			"index and function arguments",
+			"{{index . \"Old\"}} {{json .Old}} {{toml (index $ \"Old\")}} {{.Older}}\n",
+			"New",
+			"{{index . \"New\"}} {{json .New}} {{toml (index $ \"New\")}} {{.Older}}\n",
+			3,
+			false,
+		},
+		{
+			"new name that isn't an identifier in an index",
+			"{{index . \"Old\"}}\n",
+			"db.password",
+			"{{index . \"db.password\"}}\n",
+			1,
+			false,
+		},
+		{
+			"new name that isn't an identifier in a field",
+			"{{index . \"Old\"}}\n{{.Old}}\n",
+			"db.password",
+			"",
+			0,
+			true,
+		},
+		{
+			"no references",
+			"{{.Other}}\n",
+			"New",
+			"{{.Other}}\n",
+			0,
+			false,
+		},
+	}
+
+	inputTemplate := filepath.Join(t.TempDir(), "test.txt.jgrt")
+	for _, test := range tests {
+		if err := ioutil.WriteFile(inputTemplate, []byte(test.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+		rewritten, references, err := rewriteTemplateReferences(inputTemplate, "Old", test.newName)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, rewritten)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if string(rewritten) != test.want || references != test.wantReferences {
+			t.Errorf("%s: rewrote %d references to %q, want %d to %q", test.name, references, rewritten, test.wantReferences, test.want)
+		}
+	}
+}
+
+func TestRenameJaegerDB(t *testing.T) {
+	entitylist := testEntityList(t)
+	dir := t.TempDir()
+	jsonGPGDB := filepath.Join(dir, "test.txt.jgrdb")
+	inputTemplate := filepath.Join(dir, "test.txt.jgrt")
+
+	tests := []struct {
+		name         string
+		template     string
+		newName      string
+		copy         bool
+		rewrite      bool
+		want         map[string]string
+		wantTemplate string
+		wantErr      bool
+	}{
+		{"rename", "{{.Old}}\n", "New", false, true, map[string]string{"New": "a", "Other": "b"}, "{{.New}}\n", false},
+		{"copy", "{{.Old}}\n", "New", true, false, map[string]string{"Old": "a", "New": "a", "Other": "b"}, "{{.Old}}\n", false},
+		{"empty name", "{{.Old}}\n", "", false, true, nil, "", true},
+		{"name with a space around it", "{{.Old}}\n", " New", false, true, nil, "", true},
+		{"name with a line break", "{{.Old}}\n", "New\nName", false, true, nil, "", true},
+		{"existing name", "{{.Old}}\n", "Other", false, true, nil, "", true},
+		{"template that can't be rewritten", "{{.Old}}\n", "db.password", false, true, nil, "", true},
+	}
+	for _, test := range tests {
+		writeTestStore(t, jsonGPGDB, map[string]string{"Old": "a", "Other": "b"}, entitylist)
+		if err := ioutil.WriteFile(inputTemplate, []byte(test.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+		before, err := ioutil.ReadFile(jsonGPGDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		templateArg := ""
+		if test.rewrite {
+			templateArg = inputTemplate
+		}
+
+		_, err = renameJaegerDB("Old", test.newName, jsonGPGDB, test.copy, templateArg)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			// Neither file is changed
+			if after, _ := ioutil.ReadFile(jsonGPGDB); string(after) != string(before) {
+				t.Errorf("%s: JSON GPG database file changed after an error", test.name)
+			}
+			if content, _ := ioutil.ReadFile(inputTemplate); string(content) != test.template {
+				t.Errorf("%s: Template = %q after an error, want %q", test.name, content, test.template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if values := readTestStore(t, jsonGPGDB, entitylist); !reflect.DeepEqual(values, test.want) {
+			t.Errorf("%s: properties = %q, want %q", test.name, values, test.want)
+		}
+		if content, _ := ioutil.ReadFile(inputTemplate); string(content) != test.wantTemplate {
+			t.Errorf("%s: Template = %q, want %q", test.name, content, test.wantTemplate)
+		}
+	}
+}
+
+func TestRenameJaegerDBRestoresTemplate(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions don't apply to root")
+	}
+	entitylist := testEntityList(t)
+	dir := t.TempDir()
+	jsonGPGDB := filepath.Join(dir, "test.txt.jgrdb")
+	inputTemplate := filepath.Join(dir, "test.txt.jgrt")
+	writeTestStore(t, jsonGPGDB, map[string]string{"Old": "a"}, entitylist)
+	if err := ioutil.WriteFile(inputTemplate, []byte("{{.Old}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(jsonGPGDB, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := renameJaegerDB("Old", "New", jsonGPGDB, false, inputTemplate); err == nil {
+		t.Fatal("renameJaegerDB(): expected an error when the JSON GPG database file can't be written")
+	}
+	if content, _ := ioutil.ReadFile(inputTemplate); string(content) != "{{.Old}}\n" {
+		t.Errorf("Template = %q after the JSON GPG database file failed, want it put back", content)
+	}
+}
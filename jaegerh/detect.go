@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// secretDetector decides which values are sensitive enough to be extracted
+type secretDetector struct {
+	Threshold float64  // Minimum score to extract a value. 0 extracts everything
+	Allow     []string // Key patterns that are never extracted, eg. *.host
+	Deny      []string // Key patterns that are always extracted, eg. *.user
+}
+
+var secretFormats = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"aws access key", regexp.MustCompile(`^(AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}$`)},
+	{"aws secret key", regexp.MustCompile(`^[A-Za-z0-9/+]{40}$`)},
+	{"pem block", regexp.MustCompile(`-----BEGIN [A-Z ]*(PRIVATE KEY|CERTIFICATE)-----`)},
+	{"jwt", regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`)},
+	{"connection string with credentials", regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://[^/:@\s]*:[^/@\s]+@`)},
+	{"credentials in parameters", regexp.MustCompile(`(?i)(^|[?&;\s])(password|pwd|secret|token)=[^&;\s]+`)},
+}
+
+// Words in a key name that mark its value as sensitive
+var secretKeyWords = map[string]bool{
+	"password": true, "passwd": true, "pwd": true, "pass": true, "passphrase": true,
+	"secret": true, "token": true, "key": true, "apikey": true, "dsn": true,
+	"credential": true, "credentials": true, "auth": true, "private": true, "salt": true,
+}
+
+var randomToken = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+
+var keyWords = regexp.MustCompile(`[A-Z]+[a-z0-9]*|[a-z0-9]+`)
+
+// Score rates how likely a value is to be a secret, from 0 to 1, and gives the reason
+func (d secretDetector) Score(rawKey string, value string) (float64, string) {
+	for _, format := range secretFormats {
+		if format.pattern.MatchString(value) && (format.name != "aws secret key" || shannonEntropy(value) > 4) {
+			return 1, format.name
+		}
+	}
+
+	score, reason := 0.0, "no match"
+	for _, word := range keyWords.FindAllString(rawKey, -1) {
+		if secretKeyWords[strings.ToLower(word)] {
+			score, reason = 0.6, "key name"
+			break
+		}
+	}
+	for _, word := range []string{"password", "passwd", "secret", "token", "credential", "apikey"} {
+		if strings.Contains(strings.ToLower(rawKey), word) {
+			score, reason = 0.6, "key name"
+		}
+	}
+
+	// Long random looking tokens, eg. generated API keys. Words, host names and paths are left out.
+	if entropy := shannonEntropy(value); len(value) >= 16 && randomToken.MatchString(value) &&
+		strings.ContainsAny(value, "0123456789") && strings.ContainsAny(strings.ToLower(value), "abcdefghijklmnopqrstuvwxyz") {
+		score += math.Max(0, math.Min((entropy-2.5)/2, 0.5))
+		if reason == "no match" {
+			reason = "entropy"
+		} else {
+			reason += " and entropy"
+		}
+	}
+
+	// Numbers and booleans are usually settings such as sizes or flags rather than secrets, unless the key name
+	// says otherwise, eg. a numeric PIN or password
+	if !strings.HasPrefix(reason, "key name") {
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			score -= 0.3
+		} else if _, err := strconv.ParseBool(value); err == nil {
+			score -= 0.3
+		}
+	}
+
+	return math.Max(0, math.Min(score, 1)), reason
+}
+
+// IsSecret applies the allow and deny lists, then the Threshold to the score
+func (d secretDetector) IsSecret(rawKey string, value string) (bool, string) {
+	if matchKey(d.Deny, rawKey) {
+		return true, "deny list"
+	}
+	if matchKey(d.Allow, rawKey) {
+		return false, "allow list"
+	}
+	score, reason := d.Score(rawKey, value)
+	return score >= d.Threshold, fmt.Sprintf("%s, score %.2f", reason, score)
+}
+
+func matchKey(patterns []string, rawKey string) bool {
+	// Case insensitive glob patterns matched against the key as written in the input file
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(rawKey)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func shannonEntropy(s string) float64 {
+	// Bits per character
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(s, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Input file formats
+const (
+	formatKeyValue = "keyvalue" // key = value lines, the default
+	formatEnv      = "env"
+	formatINI      = "ini"
+	formatTOML     = "toml"
+	formatYAML     = "yaml"
+	formatJSON     = "json"
+)
+
+// extraction is a Template built from an input file and the properties taken out of it
+type extraction struct {
+	Template   string
+	Properties []extractedProperty
+}
+
+type extractedProperty struct {
+	RawKey      string // Key as written in the input file, namespaced by section or parent keys. eg. prod.database.password
+	Key         string // Property name. eg. ProdDatabasePassword
+	Placeholder string // Template action for the property. eg. {{.ProdDatabasePassword}}
+	Value       string
+}
+
+func detectFormat(inputFile string, override string) (string, error) {
+	if override != "" {
+		switch override {
+		case formatKeyValue, formatEnv, formatINI, formatTOML, formatYAML, formatJSON:
+			return override, nil
+		}
+		return "", fmt.Errorf("ERROR: Unknown format: %v. Use keyvalue, env, ini, toml, yaml or json", override)
+	}
+
+	base := strings.ToLower(filepath.Base(inputFile))
+	switch ext := filepath.Ext(base); {
+	case ext == ".env" || base == ".env" || strings.HasPrefix(base, ".env."):
+		return formatEnv, nil
+	case ext == ".ini":
+		return formatINI, nil
+	case ext == ".toml":
+		return formatTOML, nil
+	case ext == ".yaml" || ext == ".yml":
+		return formatYAML, nil
+	case ext == ".json":
+		return formatJSON, nil
+	}
+	return formatKeyValue, nil
+}
+
+func extract(format string, content []byte, detector secretDetector, namer *propertyNamer) (extraction, error) {
+	b := &templateBuilder{detector: detector, namer: namer, quote: quoteFunction(format)}
+	var err error
+	switch format {
+	case formatEnv:
+		err = extractEnv(b, content)
+	case formatINI:
+		err = extractINI(b, content)
+	case formatTOML:
+		err = extractTOML(b, content)
+	case formatYAML:
+		err = extractYAML(b, content)
+	case formatJSON:
+		err = extractJSON(b, content)
+	default:
+		err = extractKeyValue(b, content)
+	}
+	if err != nil {
+		return extraction{}, err
+	}
+	return extraction{Template: b.buf.String(), Properties: b.properties}, nil
+}
+
+// quoteFunction is the Template function that renders a double quoted string the way the format escapes it
+func quoteFunction(format string) string {
+	switch format {
+	case formatJSON:
+		return "json"
+	case formatTOML:
+		return "toml"
+	case formatEnv:
+		return "dotenv"
+	}
+	return `printf "%q"`
+}
+
+// templateBuilder accumulates Template text, replacing values with placeholders
+type templateBuilder struct {
+	buf        bytes.Buffer
+	properties []extractedProperty
+	detector   secretDetector
+	namer      *propertyNamer
+	quote      string // Template function for double quoted values. eg. json
+}
+
+func (b *templateBuilder) Text(s string) {
+	b.buf.WriteString(escapeTemplateText(s))
+}
+
+// Value adds a placeholder for a value written as is, eg. inside single quotes or unquoted
+func (b *templateBuilder) Value(rawKey string, value string) {
+	b.buf.WriteString(b.add(rawKey, value, ""))
+}
+
+// QuotedValue adds a placeholder for a double quoted string including its quotes, so the rendered value is
+// quoted and escaped whatever it contains
+func (b *templateBuilder) QuotedValue(rawKey string, value string) {
+	b.buf.WriteString(b.add(rawKey, value, b.quote))
+}
+
+func (b *templateBuilder) add(rawKey string, value string, quote string) string {
+	key := b.namer.Name(rawKey, value)
+	placeholder := b.namer.Placeholder(key, quote)
+	b.properties = append(b.properties, extractedProperty{RawKey: rawKey, Key: key, Placeholder: placeholder, Value: value})
+	return placeholder
+}
+
+// Secret reports whether a value should be extracted rather than left in the Template
+func (b *templateBuilder) Secret(rawKey string, value string) bool {
+	secret, reason := b.detector.IsSecret(rawKey, value)
+	jaegerlog.Debug("scored value", "key", rawKey, "secret", secret, "reason", reason, jaegerlog.Secret("value", value))
+	return secret
+}
+
+// Line adds a line with the value between start and end replaced by a placeholder, if the value is a secret
+func (b *templateBuilder) Line(line string, start int, end int, rawKey string, value string, quoted bool) {
+	if !b.Secret(rawKey, value) {
+		b.Text(line)
+		return
+	}
+	b.Text(line[:start])
+	if quoted {
+		b.QuotedValue(rawKey, value)
+	} else {
+		b.Value(rawKey, value)
+	}
+	b.Text(line[end:])
+}
+
+func eachLine(b *templateBuilder, content []byte, fn func(line string) error) error {
+	// Call fn for each line. Line endings are written back to the Template so the file ending is preserved.
+	lines := strings.SplitAfter(string(content), "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		ending := ""
+		if strings.HasSuffix(line, "\n") {
+			ending = "\n"
+			line = strings.TrimSuffix(line, "\n")
+		}
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r" + ending
+			line = strings.TrimSuffix(line, "\r")
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+		b.Text(ending)
+	}
+	return nil
+}
+
+var commentLine = regexp.MustCompile("^[[:space:]]*#")
+
+func extractKeyValue(b *templateBuilder, content []byte) error {
+	// key = value lines split at the first '=', so values such as URLs may contain '='
+	return eachLine(b, content, func(line string) error {
+		if commentLine.MatchString(line) || !strings.Contains(line, "=") {
+			b.Text(line)
+			return nil
+		}
+		s := strings.SplitN(line, "=", 2)
+		rawKey := strings.TrimSpace(s[0])
+		value := strings.TrimSpace(s[1])
+		if rawKey == "" || value == "" {
+			b.Text(line)
+			return nil
+		}
+		start := len(s[0]) + 1 + len(s[1]) - len(strings.TrimLeft(s[1], " \t"))
+		b.Line(line, start, start+len(value), rawKey, value, false)
+		return nil
+	})
+}
+
+var envLine = regexp.MustCompile(`^(\s*(?:export\s+)?)([A-Za-z_][A-Za-z0-9_.]*)(\s*=\s*)(.*)$`)
+
+func extractEnv(b *templateBuilder, content []byte) error {
+	lineNumber := 0
+	return eachLine(b, content, func(line string) error {
+		lineNumber++
+		m := envLine.FindStringSubmatch(line)
+		if m == nil || commentLine.MatchString(line) {
+			b.Text(line)
+			return nil
+		}
+		rawKey, rest := m[2], m[4]
+		start := len(m[1]) + len(m[2]) + len(m[3])
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			value, after, err := jaegertemplate.UnquoteDotenv(rest)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid double quoted value", lineNumber)
+			}
+			b.Line(line, start, start+len(rest)-len(after), rawKey, value, true)
+		case strings.HasPrefix(rest, "'"):
+			end := strings.Index(rest[1:], "'")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated single quote", lineNumber)
+			}
+			b.Line(line, start+1, start+1+end, rawKey, rest[1:1+end], false)
+		default:
+			value := rest
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				b.Text(line)
+				return nil
+			}
+			b.Line(line, start, start+len(value), rawKey, value, false)
+		}
+		return nil
+	})
+}
+
+var iniSectionLine = regexp.MustCompile(`^\s*\[([^\]]+)\]`)
+
+func extractINI(b *templateBuilder, content []byte) error {
+	// Keys are namespaced by their section, eg. password in [prod] becomes prod.password
+	section := ""
+	lineNumber := 0
+	return eachLine(b, content, func(line string) error {
+		lineNumber++
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			b.Text(line)
+			return nil
+		}
+		if m := iniSectionLine.FindStringSubmatch(line); m != nil {
+			section = strings.TrimSpace(m[1])
+			b.Text(line)
+			return nil
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			b.Text(line)
+			return nil
+		}
+		key := strings.TrimSpace(line[:sep])
+		rest := line[sep+1:]
+		value := strings.TrimSpace(rest)
+		if key == "" || value == "" {
+			b.Text(line)
+			return nil
+		}
+		start := sep + 1 + len(rest) - len(strings.TrimLeft(rest, " \t"))
+		end := start + len(value)
+		rawKey := key
+		if section != "" {
+			rawKey = section + "." + key
+		}
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			// Replaced with its quotes, so the rendered value is escaped whatever it contains
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid double quoted value", lineNumber)
+			}
+			b.Line(line, start, end, rawKey, unquoted, true)
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			// Single quoted values have no escapes, so the quotes are kept around the value
+			b.Line(line, start+1, end-1, rawKey, value[1:len(value)-1], false)
+		default:
+			b.Line(line, start, end, rawKey, value, false)
+		}
+		return nil
+	})
+}
+
+var tomlTableLine = regexp.MustCompile(`^\s*(\[\[?)\s*([^\]]+?)\s*\]\]?`)
+var tomlKeyValue = regexp.MustCompile(`^(\s*)((?:"[^"]*"|'[^']*'|[A-Za-z0-9_-]+)(?:\s*\.\s*(?:"[^"]*"|'[^']*'|[A-Za-z0-9_-]+))*)(\s*=\s*)`)
+
+func extractTOML(b *templateBuilder, content []byte) error {
+	// Keys are namespaced by their table. Elements of an array of tables are numbered, eg. products.0.name
+	table := ""
+	arrayTables := make(map[string]int)
+	multiline := "" // Closing delimiter of an open multi-line string
+	depth := 0      // Nesting of an open multi-line array or inline table
+	lineNumber := 0
+	return eachLine(b, content, func(line string) error {
+		lineNumber++
+		switch {
+		case multiline != "":
+			if strings.Contains(line, multiline) {
+				multiline = ""
+			}
+			b.Text(line)
+			return nil
+		case depth > 0:
+			depth += tomlBracketDepth(line)
+			b.Text(line)
+			return nil
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			b.Text(line)
+			return nil
+		}
+		if m := tomlTableLine.FindStringSubmatch(line); m != nil {
+			table = tomlKeyPath(m[2])
+			if m[1] == "[[" {
+				index := arrayTables[table]
+				arrayTables[table] = index + 1
+				table = fmt.Sprintf("%s.%d", table, index)
+			}
+			b.Text(line)
+			return nil
+		}
+
+		m := tomlKeyValue.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		rawKey := tomlKeyPath(m[2])
+		if table != "" {
+			rawKey = table + "." + rawKey
+		}
+		start := len(m[0])
+		rest := line[start:]
+		switch {
+		case strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''"):
+			if !strings.Contains(rest[3:], rest[:3]) {
+				multiline = rest[:3]
+			}
+			b.Text(line)
+		case strings.HasPrefix(rest, "[") || strings.HasPrefix(rest, "{"):
+			depth = tomlBracketDepth(rest)
+			b.Text(line)
+		case strings.HasPrefix(rest, `"`):
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid string", lineNumber)
+			}
+			value, _ := strconv.Unquote(quoted)
+			b.Line(line, start, start+len(quoted), rawKey, value, true)
+		case strings.HasPrefix(rest, "'"):
+			end := strings.Index(rest[1:], "'")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated string", lineNumber)
+			}
+			b.Line(line, start+1, start+1+end, rawKey, rest[1:1+end], false)
+		default:
+			value := strings.TrimSpace(strings.SplitN(rest, "#", 2)[0])
+			if value == "" {
+				return fmt.Errorf("line %d: missing value", lineNumber)
+			}
+			b.Line(line, start, start+len(value), rawKey, value, false)
+		}
+		return nil
+	})
+}
+
+func tomlKeyPath(key string) string {
+	// Normalize a dotted TOML key, removing quotes and spaces around the dots
+	var parts []string
+	for _, part := range regexp.MustCompile(`"[^"]*"|'[^']*'|[^.\s]+`).FindAllString(key, -1) {
+		parts = append(parts, strings.Trim(part, `"'`))
+	}
+	return strings.Join(parts, ".")
+}
+
+func tomlBracketDepth(s string) int {
+	// Net brackets opened on a line, outside quoted strings and comments
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+var yamlKeyValue = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"\[\]{},&*!|>%@-][^:#]*?|-[^\s:#][^:#]*?)\s*:(\s+|$)`)
+
+type yamlLevel struct {
+	indent   int
+	name     string
+	sequence bool // A sequence item rather than a mapping key
+}
+
+func extractYAML(b *templateBuilder, content []byte) error {
+	// Nested mapping keys are joined with '.', eg. database.password. Sequence items are numbered.
+	var stack []yamlLevel
+	sequences := make(map[string]int) // Next index of each sequence, by path and indent
+	blockIndent := -1                 // Indentation of the key that started a | or > block scalar
+	lineNumber := 0
+
+	path := func(name string) string {
+		var names []string
+		for _, level := range stack {
+			names = append(names, level.name)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+		return strings.Join(names, ".")
+	}
+
+	return eachLine(b, content, func(line string) error {
+		lineNumber++
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				b.Text(line)
+				return nil
+			}
+			blockIndent = -1
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" || trimmed == "..." || strings.HasPrefix(trimmed, "%") {
+			if trimmed == "---" {
+				stack = nil
+				sequences = make(map[string]int)
+			}
+			b.Text(line)
+			return nil
+		}
+
+		isItem := strings.HasPrefix(trimmed, "- ") || trimmed == "-"
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			// A sequence may be written at the same indentation as the key that holds it
+			if top.indent < indent || (isItem && !top.sequence && top.indent == indent) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		// A sequence item is numbered and its content handled as if it were indented past the '- '
+		offset := indent
+		item := false
+		for strings.HasPrefix(line[offset:], "- ") || line[offset:] == "-" {
+			seqKey := fmt.Sprintf("%s@%d", path(""), offset)
+			index := sequences[seqKey]
+			sequences[seqKey] = index + 1
+			stack = append(stack, yamlLevel{indent: offset, name: strconv.Itoa(index), sequence: true})
+			offset += 1 + len(line[offset+1:]) - len(strings.TrimLeft(line[offset+1:], " "))
+			item = true
+			if offset >= len(line) {
+				b.Text(line)
+				return nil
+			}
+		}
+
+		keyStart := offset
+		rest := line[offset:]
+		name := ""
+		if m := yamlKeyValue.FindString(rest); m != "" {
+			name = strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(m), ":")), `"'`)
+			offset += len(m)
+			rest = line[offset:]
+		} else if item {
+			// A scalar sequence item, named by its index
+			name = stack[len(stack)-1].name
+			stack = stack[:len(stack)-1]
+		} else {
+			b.Text(line)
+			return nil
+		}
+
+		value := rest
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		if strings.HasPrefix(strings.TrimSpace(value), "#") {
+			value = ""
+		}
+		value = strings.TrimRight(value, " \t")
+
+		switch {
+		case value == "":
+			// A nested mapping or sequence follows
+			stack = append(stack, yamlLevel{indent: keyStart, name: name})
+			b.Text(line)
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			blockIndent = keyStart
+			b.Text(line)
+		case strings.ContainsAny(value[:1], "[{&*!"):
+			// Flow collections, anchors, aliases and tags are kept as they are
+			b.Text(line)
+		case value[0] == '"':
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid double quoted value", lineNumber)
+			}
+			unquoted, _ := strconv.Unquote(quoted)
+			b.Line(line, offset, offset+len(quoted), path(name), unquoted, true)
+		case value[0] == '\'':
+			end := strings.LastIndex(value, "'")
+			if end == 0 {
+				return fmt.Errorf("line %d: unterminated single quote", lineNumber)
+			}
+			inner := value[1:end]
+			if strings.Contains(inner, "''") {
+				// An escaped quote can't be reproduced inside single quotes, so switch to double quotes
+				b.Line(line, offset, offset+end+1, path(name), strings.Replace(inner, "''", "'", -1), true)
+			} else {
+				b.Line(line, offset+1, offset+end, path(name), inner, false)
+			}
+		default:
+			b.Line(line, offset, offset+len(value), path(name), value, false)
+		}
+		return nil
+	})
+}
+
+func extractJSON(b *templateBuilder, content []byte) error {
+	// Walk the document keeping the original text, replacing strings, numbers and booleans with placeholders
+	if !json.Valid(content) {
+		var v interface{}
+		return json.Unmarshal(content, &v)
+	}
+	s := &jsonScanner{b: b, data: string(content)}
+	s.value(nil)
+	s.skipSpace()
+	b.Text(s.data[s.written:])
+	return nil
+}
+
+type jsonScanner struct {
+	b       *templateBuilder
+	data    string
+	pos     int
+	written int // Text before this position has been added to the Template
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *jsonScanner) placeholder(path []string, start int, value string, quoted bool) {
+	if !s.b.Secret(strings.Join(path, "."), value) {
+		return
+	}
+	s.b.Text(s.data[s.written:start])
+	if quoted {
+		s.b.QuotedValue(strings.Join(path, "."), value)
+	} else {
+		s.b.Value(strings.Join(path, "."), value)
+	}
+	s.written = s.pos
+}
+
+func (s *jsonScanner) str() string {
+	start := s.pos
+	s.pos++
+	for s.data[s.pos] != '"' {
+		if s.data[s.pos] == '\\' {
+			s.pos++
+		}
+		s.pos++
+	}
+	s.pos++
+	var value string
+	json.Unmarshal([]byte(s.data[start:s.pos]), &value)
+	return value
+}
+
+func (s *jsonScanner) value(path []string) {
+	// The document is known to be valid so no errors are checked
+	s.skipSpace()
+	start := s.pos
+	switch c := s.data[s.pos]; {
+	case c == '{':
+		s.pos++
+		for {
+			s.skipSpace()
+			if s.data[s.pos] == '}' {
+				s.pos++
+				return
+			}
+			if s.data[s.pos] == ',' {
+				s.pos++
+				s.skipSpace()
+			}
+			name := s.str()
+			s.skipSpace()
+			s.pos++ // ':'
+			s.value(append(path[:len(path):len(path)], name))
+		}
+	case c == '[':
+		s.pos++
+		for index := 0; ; index++ {
+			s.skipSpace()
+			if s.data[s.pos] == ']' {
+				s.pos++
+				return
+			}
+			if s.data[s.pos] == ',' {
+				s.pos++
+			}
+			s.value(append(path[:len(path):len(path)], strconv.Itoa(index)))
+		}
+	case c == '"':
+		value := s.str()
+		if value != "" {
+			s.placeholder(path, start, value, true)
+		}
+	case c == 'n':
+		s.pos += len("null")
+	default:
+		for s.pos < len(s.data) && strings.IndexByte(",}] \t\r\n", s.data[s.pos]) < 0 {
+			s.pos++
+		}
+		s.placeholder(path, start, s.data[start:s.pos], false)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		inputFile string
+		override  string
+		want      string
+		wantErr   bool
+	}{
+		{"app.conf", "", formatKeyValue, false},
+		{".env", "", formatEnv, false},
+		{".env.production", "", formatEnv, false},
+		{"prod.env", "", formatEnv, false},
+		{"app.INI", "", formatINI, false},
+		{"app.cfg", "", formatKeyValue, false},
+		{"app.toml", "", formatTOML, false},
+		{"app.yml", "", formatYAML, false},
+		{"app.json", "", formatJSON, false},
+		{"app.conf", formatINI, formatINI, false},
+		{"app.conf", "xml", "", true},
+	}
+	for _, test := range tests {
+		got, err := detectFormat(test.inputFile, test.override)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("detectFormat(%q, %q): expected an error, got %s", test.inputFile, test.override, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("detectFormat(%q, %q): unexpected error: %v", test.inputFile, test.override, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("detectFormat(%q, %q) = %s, want %s", test.inputFile, test.override, got, test.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		input      string
+		want       string
+		properties map[string]string // Value by raw key
+		wantErr    bool
+	}{
+		{
+			"key value",
+			formatKeyValue,
+			"# comment\nhost = db.example.com\npassword = hunter2\nurl=postgres://u:pw@host/db?a=b\n",
+			"# comment\nhost = db.example.com\npassword = {{.Password}}\nurl={{.Url}}\n",
+			map[string]string{"password": "hunter2", "url": "postgres://u:pw@host/db?a=b"},
+			false,
+		},
+		{
+			"env",
+			formatEnv,
+			"export PASSWORD=\"it's \\$x\\n\" # comment\nTOKEN='hunter2'\nHOST=db.example.com\n",
+			"export PASSWORD={{dotenv .PASSWORD}} # comment\nTOKEN='{{.TOKEN}}'\nHOST=db.example.com\n",
+			map[string]string{"PASSWORD": "it's $x\n", "TOKEN": "hunter2"},
+			false,
+		},
+		{
+			"env unterminated double quote",
+			formatEnv,
+			"PASSWORD=\"hunter2\n",
+			"",
+			nil,
+			true,
+		},
+		{
+			"ini sections",
+			formatINI,
+			"password = hunter2\n[prod]\n; comment\npassword = \"a\\\"b\"\nsecret: 'c d'\nhost = db.example.com\n",
+			"password = {{.Password}}\n[prod]\n; comment\npassword = {{printf \"%q\" .ProdPassword}}\nsecret: '{{.ProdSecret}}'\nhost = db.example.com\n",
+			map[string]string{"password": "hunter2", "prod.password": "a\"b", "prod.secret": "c d"},
+			false,
+		},
+		{
+			"ini invalid double quote",
+			formatINI,
+			"password = \"a\\qb\"\n",
+			"",
+			nil,
+			true,
+		},
+		{
+			"toml tables",
+			formatTOML,
+			"password = \"hunter2\"\n[db.prod]\npassword = 'x\\y'\nport = 5432\n[[users]]\ntoken = \"t1\"\n[[users]]\ntoken = \"t2\"\n",
+			"password = {{toml .Password}}\n[db.prod]\npassword = '{{.DbProdPassword}}'\nport = 5432\n[[users]]\ntoken = {{toml .Users0Token}}\n[[users]]\ntoken = {{toml .Users1Token}}\n",
+			map[string]string{"password": "hunter2", "db.prod.password": `x\y`, "users.0.token": "t1", "users.1.token": "t2"},
+			false,
+		},
+		{
+			"yaml nesting",
+			formatYAML,
+			"db:\n  password: hunter2\n  host: db.example.com\ntokens:\n  - \"t\\\"1\"\n",
+			"db:\n  password: {{.DbPassword}}\n  host: db.example.com\ntokens:\n  - {{printf \"%q\" .Tokens0}}\n",
+			map[string]string{"db.password": "hunter2", "tokens.0": "t\"1"},
+			false,
+		},
+		{
+			"json nesting",
+			formatJSON,
+			"{\"db\": {\"password\": \"a\\u0022b\", \"port\": 5432}, \"tokens\": [\"t1\"]}\n",
+			"{\"db\": {\"password\": {{json .DbPassword}}, \"port\": 5432}, \"tokens\": [{{json .Tokens0}}]}\n",
+			map[string]string{"db.password": "a\"b", "tokens.0": "t1"},
+			false,
+		},
+	}
+	detector := secretDetector{Threshold: 0.5, Deny: []string{"url", "token", "*.token", "tokens.*", "*secret"}}
+	for _, test := range tests {
+		namer, err := newPropertyNamer(namingCamel, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := extract(test.format, []byte(test.input), detector, namer)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, got.Template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got.Template != test.want {
+			t.Errorf("%s: Template = %q, want %q", test.name, got.Template, test.want)
+		}
+		properties := make(map[string]string)
+		for _, property := range got.Properties {
+			properties[property.RawKey] = property.Value
+		}
+		if !reflect.DeepEqual(properties, test.properties) {
+			t.Errorf("%s: properties = %q, want %q", test.name, properties, test.properties)
+		}
+	}
+}
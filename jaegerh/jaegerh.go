@@ -1,38 +1,47 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
+	"github.com/jyap808/jaeger/jaegeraudit"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 const jaegerTemplateExtension = ".jgrt"
+const jaegerDBExtension = ".jgrdb"
 const jaegerDescription = "JaegerH - Jaeger Helper program\n\nJaeger is a JSON encoded GPG encrypted key value store. It is useful for separating development with operations and keeping configuration files secure."
 const jaegerQuote = "\"Stacker Pentecost: Haven't you heard Mr. Beckett? The world is coming to an end. So where would you rather die? Here? Or in a Jaeger!\" - Pacific Rim"
-const jaegerRecommendedUsage = "RECOMMENDED:\n    jaegerh -i file.txt.jgrt"
-
-var debug debugging = false
-
-type debugging bool
-
-func (d debugging) Printf(format string, args ...interface{}) {
-	// From: https://groups.google.com/forum/#!msg/golang-nuts/gU7oQGoCkmg/BNIl-TqB-4wJ
-	if d {
-		log.Printf(format, args...)
-	}
-}
+const jaegerRecommendedUsage = "RECOMMENDED:\n    jaegerh -i file.txt.jgrt\n\nTo write file.txt.jgrt and file.txt.jgrdb from an existing file.txt:\n    jaegerh -i file.txt -write\n\nTo recover file.txt.jgrdb from file.txt.jgrt and an existing file.txt:\n    jaegerh -reverse -i file.txt.jgrt -from file.txt"
 
 func main() {
 	// Define flags
+	logFlags := jaegerlog.RegisterFlags()
 	var (
-		debugFlag     = flag.Bool("d", false, "Enable Debug")
-		inputTemplate = flag.String("i", "", "Input Template file. eg. file.txt.jgrt")
+		inputTemplate  = flag.String("i", "", "Input Template file. eg. file.txt.jgrt")
+		jsonGPGDB      = flag.String("j", "", "JSON GPG database file written by -write. Defaults to the input file name with a .jgrdb extension")
+		keyringFile    = flag.String("k", "", "Keyring file used by -write. Public key in ASCII armored format. eg. pubring.asc")
+		formatFlag     = flag.String("format", "", "Input file format: keyvalue, env, ini, toml, yaml or json. Detected from the file extension by default, falling back to keyvalue")
+		threshold      = flag.Float64("threshold", 0.5, "Minimum secret score, from 0 to 1, for a value to be extracted. 0 extracts every value")
+		allowKeys      = flag.String("allow", "", "Comma separated key patterns that are never extracted. eg. '*.host,*.port'")
+		denyKeys       = flag.String("deny", "", "Comma separated key patterns that are always extracted. eg. 'db.user'")
+		naming         = flag.String("naming", namingCamel, "Property naming: camel (ProdDatabasePassword), snake (prod_database_password) or index (prod.database.password, used as {{index . \"prod.database.password\"}})")
+		prefix         = flag.String("prefix", "", "Prefix added to every property name. eg. App")
+		dedupe         = flag.Bool("dedupe", true, "Use one property for a value that appears under several keys")
+		reverseFlag    = flag.Bool("reverse", false, "Recover the property values from a file rendered from the input Template (-from) and encrypt them into the JSON GPG database file")
+		fromFile       = flag.String("from", "", "Existing file rendered from the input Template, used by -reverse. eg. file.txt")
+		writeFlag      = flag.Bool("write", false, "Write a Template file with each value replaced by a placeholder and encrypt the values into a JSON GPG database file, instead of printing jaegerdb commands")
+		signKey        = flag.String("sign-key", "", "Your personal private key, in ASCII armored format, that signs the audit log entries of -write and -reverse. Defaults to ~/.gnupg/secring.gpg if it exists, otherwise entries are unsigned")
+		signPassphrase = flag.String("sign-passphrase", "", "Passphrase for -sign-key. If this is not set the passphrase will be blank or read from the environment variable SIGN_PASSPHRASE.")
 	)
 
 	flag.Usage = func() {
@@ -43,8 +52,13 @@ func main() {
 
 	flag.Parse()
 
-	if *debugFlag {
-		debug = true
+	if err := logFlags.Setup(); err != nil {
+		flag.Usage()
+		log.Fatalf("\n\n%s", err)
+	}
+
+	if *signPassphrase == "" {
+		*signPassphrase = os.Getenv("SIGN_PASSPHRASE")
 	}
 
 	if *inputTemplate == "" {
@@ -56,7 +70,99 @@ func main() {
 		*inputTemplate = assumedTemplate
 	}
 
-	processInputFile(inputTemplate)
+	if *reverseFlag {
+		if *fromFile == "" {
+			*fromFile = strings.TrimSuffix(*inputTemplate, jaegerTemplateExtension)
+		}
+		if *jsonGPGDB == "" {
+			*jsonGPGDB = strings.TrimSuffix(*inputTemplate, jaegerTemplateExtension) + jaegerDBExtension
+		}
+		if err := reverseJaegerFiles(inputTemplate, fromFile, jsonGPGDB, keyringFile, signingKey(signKey, signPassphrase)); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	format, err := detectFormat(*inputTemplate, *formatFlag)
+	if err != nil {
+		flag.Usage()
+		log.Fatalf("\n\n%s", err)
+	}
+
+	detector := secretDetector{Threshold: *threshold, Allow: splitPatterns(*allowKeys), Deny: splitPatterns(*denyKeys)}
+	namer, err := newPropertyNamer(*naming, *prefix, *dedupe)
+	if err != nil {
+		flag.Usage()
+		log.Fatalf("\n\n%s", err)
+	}
+	extracted := processInputFile(inputTemplate, format, detector, namer)
+
+	if !*writeFlag {
+		printJaegerDBCommands(extracted.Properties)
+		os.Exit(0)
+	}
+
+	if *jsonGPGDB == "" {
+		*jsonGPGDB = *inputTemplate + jaegerDBExtension
+	}
+	outputTemplate := *inputTemplate + jaegerTemplateExtension
+
+	if err := writeJaegerFiles(extracted, &outputTemplate, jsonGPGDB, publicKeyRing(keyringFile), signingKey(signKey, signPassphrase)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Wrote Template file:", outputTemplate)
+	fmt.Println("Wrote JSON GPG database file:", *jsonGPGDB)
+}
+
+func publicKeyRing(keyringFile *string) openpgp.EntityList {
+	entitylist, err := jaegerstore.PublicKeyRing(*keyringFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return entitylist
+}
+
+func signingKey(signKey *string, signPassphrase *string) *openpgp.Entity {
+	signer, err := jaegeraudit.Signer(*signKey, *signPassphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return signer
+}
+
+func reverseJaegerFiles(inputTemplate *string, fromFile *string, jsonGPGDB *string, keyringFile *string, signer *openpgp.Entity) error {
+	start := time.Now()
+
+	properties, problems, err := reverseTemplate(*inputTemplate, *fromFile)
+	if err != nil {
+		return err
+	}
+	for _, property := range properties {
+		jaegerlog.Debug("recovered property", jaegerlog.Property(property.Key), jaegerlog.Secret("value", property.Value))
+	}
+
+	if len(properties) > 0 {
+		snapshot, err := jaegeraudit.TakeSnapshot(*jsonGPGDB)
+		if err != nil {
+			return err
+		}
+		added, err := addProperties(properties, jsonGPGDB, publicKeyRing(keyringFile))
+		if err != nil {
+			return err
+		}
+		if err := jaegeraudit.AppendOrRestore(*jsonGPGDB, "add", added, signer, snapshot); err != nil {
+			return err
+		}
+		fmt.Printf("Recovered %d properties from %v\n", len(properties), *fromFile)
+		fmt.Println("Wrote JSON GPG database file:", *jsonGPGDB)
+	}
+	jaegerlog.Info("reversed template", jaegerlog.Operation("reverse"), jaegerlog.Template(*inputTemplate), jaegerlog.Store(*jsonGPGDB),
+		"properties", len(properties), "problems", len(problems), jaegerlog.Duration(start))
+
+	if len(problems) > 0 {
+		return fmt.Errorf("\n\nError: Some placeholders could not be matched, add them with jaegerdb -a:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
 }
 
 func checkExistsJaegerT() (string, error) {
@@ -71,44 +177,46 @@ func checkExistsJaegerT() (string, error) {
 	return "", fmt.Errorf("No input template file specified")
 }
 
-func processInputFile(inputFile *string) {
-	file, err := os.Open(*inputFile)
+func processInputFile(inputFile *string, format string, detector secretDetector, namer *propertyNamer) extraction {
+	start := time.Now()
+
+	content, err := ioutil.ReadFile(*inputFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		parseString := scanner.Text()
-		matched, _ := regexp.MatchString("^[[:space:]]*#", parseString)
-		if matched {
-			if debug {
-				fmt.Println("Ignoring: %s", parseString)
-			}
-			continue
-		}
-		matched, _ = regexp.MatchString(".*=.*", parseString)
-		if matched {
-			parseLine(parseString)
-		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+	extracted, err := extract(format, content, detector, namer)
+	if err != nil {
+		log.Fatalf("ERROR: Unable to parse %v as %v: %v", *inputFile, format, err)
 	}
+	for _, property := range extracted.Properties {
+		jaegerlog.Debug("extracted property", jaegerlog.Property(property.Key), "key", property.RawKey, jaegerlog.Secret("value", property.Value))
+	}
+
+	jaegerlog.Info("processed input file", jaegerlog.Operation("extract"), "file", *inputFile, "format", format, jaegerlog.Duration(start))
+	return extracted
+}
+
+func escapeTemplateText(s string) string {
+	// Literal text must not be read as a template action
+	return strings.Replace(s, "{{", "{{\"{{\"}}", -1)
 }
 
-func parseLine(line string) {
-	s := regexp.MustCompile("=").Split(line, 2)
-	rawKey := strings.TrimSpace(s[0])
-	key := camelKey(rawKey)
-	value := strings.TrimSpace(s[1])
-	if value != "" {
-		fmt.Printf("jaegerdb -a %s -v '%s' # %s = {{.%s}}\n", key, value, rawKey, key)
+func printJaegerDBCommands(properties []extractedProperty) {
+	printed := make(map[string]bool)
+	for _, property := range properties {
+		if !printed[property.Key] {
+			printed[property.Key] = true
+			fmt.Printf("jaegerdb -a %s -v %s # %s = %s\n", shellQuote(property.Key), shellQuote(property.Value), property.RawKey, property.Placeholder)
+		}
 	}
 }
 
+func shellQuote(s string) string {
+	// Single quotes preserve everything literally except a single quote, which is closed, escaped and reopened
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 func camelKey(src string) string {
 	// From: https://github.com/etgryphon/stringUp
 
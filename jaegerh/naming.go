@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"strconv"
+	"strings"
+)
+
+// Property naming strategies
+const (
+	namingCamel = "camel" // prod.database.password becomes ProdDatabasePassword
+	namingSnake = "snake" // prod.database.password becomes prod_database_password
+	namingIndex = "index" // The key is kept as is and used with {{index . "prod.database.password"}}
+)
+
+// propertyNamer names extracted properties, keeping names unique within an input file
+type propertyNamer struct {
+	Strategy string
+	Prefix   string
+	Dedupe   bool // Use one property for a value that appears under several keys
+
+	names   map[string]string // Value by property name
+	byKey   map[string]string // Property name by key and value
+	byValue map[string]string // Property name by value
+}
+
+func newPropertyNamer(strategy string, prefix string, dedupe bool) (*propertyNamer, error) {
+	switch strategy {
+	case namingCamel, namingSnake, namingIndex:
+	default:
+		return nil, fmt.Errorf("ERROR: Unknown naming strategy: %v. Use camel, snake or index", strategy)
+	}
+	if strategy != namingIndex && strings.Trim(prefix, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+		return nil, fmt.Errorf("ERROR: Invalid prefix: %v. Only letters, digits and _ can be used in a template field name", prefix)
+	}
+	return &propertyNamer{
+		Strategy: strategy,
+		Prefix:   prefix,
+		Dedupe:   dedupe,
+		names:    make(map[string]string),
+		byKey:    make(map[string]string),
+		byValue:  make(map[string]string),
+	}, nil
+}
+
+// Name returns the property name for a key and value. The same key and value always get the same name, and a
+// name already used for a different value gets a numbered suffix.
+func (n *propertyNamer) Name(rawKey string, value string) string {
+	keyValue := rawKey + "\x00" + value
+	if name, found := n.byKey[keyValue]; found {
+		return name
+	}
+	if name, found := n.byValue[value]; found && n.Dedupe {
+		jaegerlog.Debug("reusing property for duplicate value", jaegerlog.Property(name), "key", rawKey)
+		n.byKey[keyValue] = name
+		return name
+	}
+
+	base := n.baseName(rawKey)
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := n.names[name]; !taken {
+			break
+		}
+		if n.Strategy == namingCamel {
+			name = fmt.Sprintf("%s%d", base, i)
+		} else {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+	}
+	if name != base {
+		jaegerlog.Warn("property name already used by another key, renamed", jaegerlog.Property(name), "key", rawKey)
+	}
+
+	n.names[name] = value
+	n.byKey[keyValue] = name
+	if _, found := n.byValue[value]; !found {
+		n.byValue[value] = name
+	}
+	return name
+}
+
+func (n *propertyNamer) baseName(rawKey string) string {
+	var name string
+	switch n.Strategy {
+	case namingIndex:
+		return n.Prefix + rawKey
+	case namingSnake:
+		words := keyWords.FindAllString(rawKey, -1)
+		for i, word := range words {
+			words[i] = strings.ToLower(word)
+		}
+		name = n.Prefix + strings.Join(words, "_")
+	default:
+		name = n.Prefix + camelKey(rawKey)
+	}
+
+	// Template field names can't start with a digit or be empty
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// Placeholder is the template action for a property. With a quote function, eg. json, the placeholder renders
+// the value as a double quoted string.
+func (n *propertyNamer) Placeholder(name string, quote string) string {
+	value := "." + name
+	if n.Strategy == namingIndex {
+		value = "index . " + strconv.Quote(name)
+		if quote != "" {
+			value = "(" + value + ")"
+		}
+	}
+	if quote != "" {
+		return "{{" + quote + " " + value + "}}"
+	}
+	return "{{" + value + "}}"
+}
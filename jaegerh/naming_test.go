@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPropertyNamer(t *testing.T) {
+	type keyValue struct {
+		rawKey string
+		value  string
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		prefix   string
+		dedupe   bool
+		keys     []keyValue
+		want     []string
+	}{
+		{
+			"camel",
+			namingCamel, "", false,
+			[]keyValue{{"prod.database.password", "a"}, {"api-key", "b"}, {"0.token", "c"}},
+			[]string{"ProdDatabasePassword", "ApiKey", "_0Token"},
+		},
+		{
+			"snake with prefix",
+			namingSnake, "app_", false,
+			[]keyValue{{"prod.databasePassword", "a"}, {"API-KEY", "b"}},
+			[]string{"app_prod_database_password", "app_api_key"},
+		},
+		{
+			"index",
+			namingIndex, "", false,
+			[]keyValue{{"prod.database.password", "a"}},
+			[]string{"prod.database.password"},
+		},
+		{
+			"same key and value",
+			namingCamel, "", false,
+			[]keyValue{{"password", "a"}, {"password", "a"}},
+			[]string{"Password", "Password"},
+		},
+		{
+			"collision in camel case",
+			namingCamel, "", false,
+			[]keyValue{{"db.password", "a"}, {"db_password", "b"}, {"db-password", "c"}},
+			[]string{"DbPassword", "DbPassword2", "DbPassword3"},
+		},
+		{
+			"collision in snake case",
+			namingSnake, "", false,
+			[]keyValue{{"db.password", "a"}, {"db-password", "b"}},
+			[]string{"db_password", "db_password_2"},
+		},
+		{
+			"same key with a different value",
+			namingCamel, "", false,
+			[]keyValue{{"password", "a"}, {"password", "b"}},
+			[]string{"Password", "Password2"},
+		},
+		{
+			"duplicate values without dedupe",
+			namingCamel, "", false,
+			[]keyValue{{"db.password", "a"}, {"cache.password", "a"}},
+			[]string{"DbPassword", "CachePassword"},
+		},
+		{
+			"duplicate values with dedupe",
+			namingCamel, "", true,
+			[]keyValue{{"db.password", "a"}, {"cache.password", "a"}, {"other.password", "b"}},
+			[]string{"DbPassword", "DbPassword", "OtherPassword"},
+		},
+	}
+	for _, test := range tests {
+		namer, err := newPropertyNamer(test.strategy, test.prefix, test.dedupe)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for i, key := range test.keys {
+			if got := namer.Name(key.rawKey, key.value); got != test.want[i] {
+				t.Errorf("%s: Name(%q, %q) = %s, want %s", test.name, key.rawKey, key.value, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestNewPropertyNamer(t *testing.T) {
+	tests := []struct {
+		strategy string
+		prefix   string
+		wantErr  bool
+	}{
+		{namingCamel, "App", false},
+		{namingSnake, "app_", false},
+		{namingIndex, "app.", false},
+		{namingCamel, "app.", true},
+		{"kebab", "", true},
+	}
+	for _, test := range tests {
+		_, err := newPropertyNamer(test.strategy, test.prefix, false)
+		if test.wantErr && err == nil {
+			t.Errorf("newPropertyNamer(%s, %q): expected an error", test.strategy, test.prefix)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("newPropertyNamer(%s, %q): unexpected error: %v", test.strategy, test.prefix, err)
+		}
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	tests := []struct {
+		strategy string
+		name     string
+		quote    string
+		want     string
+	}{
+		{namingCamel, "DbPassword", "", "{{.DbPassword}}"},
+		{namingCamel, "DbPassword", "json", "{{json .DbPassword}}"},
+		{namingIndex, "db.password", "", `{{index . "db.password"}}`},
+		{namingIndex, "db.password", `printf "%q"`, `{{printf "%q" (index . "db.password")}}`},
+	}
+	for _, test := range tests {
+		namer, err := newPropertyNamer(test.strategy, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := namer.Placeholder(test.name, test.quote); got != test.want {
+			t.Errorf("Placeholder(%s, %s) with %s = %s, want %s", test.name, test.quote, test.strategy, got, test.want)
+		}
+	}
+}
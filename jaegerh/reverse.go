@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegertemplate"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// templateSegment is literal text or a placeholder of a Template
+type templateSegment struct {
+	Text  string // Literal text, when Key is empty
+	Key   string
+	Quote string // Function rendering the value as a double quoted string: printf, json, toml or dotenv. eg. {{json .Key}}
+	Line  int
+}
+
+// reverseTemplate recovers property values from a file rendered from a Template, by matching the literal text
+// of the Template against the file. Placeholders that can't be matched unambiguously are returned as problems.
+func reverseTemplate(templateFile string, fromFile string) ([]extractedProperty, []string, error) {
+	templateBuffer, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	fromBuffer, err := ioutil.ReadFile(fromFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	segments, err := templateSegments(string(templateBuffer))
+	if err != nil {
+		return nil, nil, fmt.Errorf("ERROR: %v: %v", templateFile, err)
+	}
+
+	// Match the whole file, which keeps repeated lines such as a password in each INI section apart. Fall back
+	// to matching line by line when lines have been added or changed outside of the placeholders.
+	values, problems, matched := matchSegments(segments, string(fromBuffer))
+	if !matched {
+		values, problems = matchLines(segments, strings.Split(string(fromBuffer), "\n"))
+		problems = append([]string{fmt.Sprintf("%v does not match the whole Template, matched line by line", fromFile)}, problems...)
+	}
+
+	var properties []extractedProperty
+	for _, segment := range segments {
+		value, found := values[segment.Key]
+		if segment.Key == "" || !found {
+			continue
+		}
+		delete(values, segment.Key)
+		properties = append(properties, extractedProperty{RawKey: segment.Key, Key: segment.Key, Value: value})
+	}
+	return properties, problems, nil
+}
+
+func templateSegments(text string) ([]templateSegment, error) {
+	// Only literal text and simple placeholders, as written by jaegerh, can be reversed
+	t, err := template.New("reverse").Funcs(jaegertemplate.FuncMap).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if t.Tree == nil {
+		return nil, nil
+	}
+
+	var segments []templateSegment
+	line := 1
+	for _, node := range t.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+			segments = append(segments, templateSegment{Text: string(node.Text), Line: line})
+			line += strings.Count(string(node.Text), "\n")
+		case *parse.ActionNode:
+			segment, ok := placeholderSegment(node)
+			if !ok {
+				return nil, fmt.Errorf("line %d: %v can't be reversed, only {{.Key}} placeholders are supported", node.Line, node)
+			}
+			segment.Line = node.Line
+			segments = append(segments, segment)
+		default:
+			return nil, fmt.Errorf("%v can't be reversed, only {{.Key}} placeholders are supported", node)
+		}
+	}
+	return segments, nil
+}
+
+func placeholderSegment(node *parse.ActionNode) (templateSegment, bool) {
+	if len(node.Pipe.Decl) > 0 || len(node.Pipe.Cmds) != 1 {
+		return templateSegment{}, false
+	}
+	args := node.Pipe.Cmds[0].Args
+
+	// Escaped literal text, eg. {{"{{"}}
+	if s, ok := args[0].(*parse.StringNode); ok && len(args) == 1 {
+		return templateSegment{Text: s.Text}, true
+	}
+
+	if name, ok := args[0].(*parse.IdentifierNode); ok && name.Ident == "printf" && len(args) == 3 {
+		if format, ok := args[1].(*parse.StringNode); !ok || format.Text != "%q" {
+			return templateSegment{}, false
+		}
+		key, ok := placeholderKey(args[2:])
+		return templateSegment{Key: key, Quote: "printf"}, ok
+	}
+	if name, ok := args[0].(*parse.IdentifierNode); ok && (name.Ident == "json" || name.Ident == "toml" || name.Ident == "dotenv") && len(args) == 2 {
+		key, ok := placeholderKey(args[1:])
+		return templateSegment{Key: key, Quote: name.Ident}, ok
+	}
+	key, ok := placeholderKey(args)
+	return templateSegment{Key: key}, ok
+}
+
+func placeholderKey(args []parse.Node) (string, bool) {
+	// .Key or index . "Key", possibly in parentheses
+	if len(args) == 1 {
+		switch arg := args[0].(type) {
+		case *parse.FieldNode:
+			return arg.Ident[0], len(arg.Ident) == 1
+		case *parse.PipeNode:
+			if len(arg.Decl) == 0 && len(arg.Cmds) == 1 {
+				return placeholderKey(arg.Cmds[0].Args)
+			}
+		}
+		return "", false
+	}
+	if len(args) == 3 {
+		name, isIdentifier := args[0].(*parse.IdentifierNode)
+		_, isDot := args[1].(*parse.DotNode)
+		key, isString := args[2].(*parse.StringNode)
+		if isIdentifier && name.Ident == "index" && isDot && isString {
+			return key.Text, true
+		}
+	}
+	return "", false
+}
+
+var quotedValue = `("(?:[^"\\]|\\.)*")`
+
+func segmentsPattern(segments []templateSegment, greedy bool) *regexp.Regexp {
+	var pattern strings.Builder
+	for _, segment := range segments {
+		switch {
+		case segment.Key == "":
+			pattern.WriteString(regexp.QuoteMeta(segment.Text))
+		case segment.Quote != "":
+			pattern.WriteString(quotedValue)
+		case greedy:
+			pattern.WriteString(`((?s:.*))`)
+		default:
+			pattern.WriteString(`((?s:.*?))`)
+		}
+	}
+	return regexp.MustCompile("^" + pattern.String() + "$")
+}
+
+func matchSegments(segments []templateSegment, content string) (map[string]string, []string, bool) {
+	// A placeholder is ambiguous when the shortest and longest match of its value differ, eg. when the text that
+	// follows it also appears in the value
+	shortest := segmentsPattern(segments, false).FindStringSubmatch(content)
+	if shortest == nil {
+		return nil, nil, false
+	}
+	longest := segmentsPattern(segments, true).FindStringSubmatch(content)
+
+	values := make(map[string]string)
+	failed := make(map[string]bool)
+	var problems []string
+	group := 0
+	for _, segment := range segments {
+		if segment.Key == "" {
+			continue
+		}
+		group++
+		value, err := segmentValue(segment, shortest[group])
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("line %d: %s: %v", segment.Line, segment.Key, err))
+		case shortest[group] != longest[group]:
+			problems = append(problems, fmt.Sprintf("line %d: %s: ambiguous, the value could be %s or %s", segment.Line, segment.Key,
+				strconv.Quote(shortest[group]), strconv.Quote(longest[group])))
+		default:
+			if previous, found := values[segment.Key]; found && previous != value {
+				problems = append(problems, fmt.Sprintf("line %d: %s: matched different values where it is used more than once", segment.Line, segment.Key))
+			} else {
+				values[segment.Key] = value
+				continue
+			}
+		}
+		failed[segment.Key] = true
+	}
+	for key := range failed {
+		delete(values, key)
+	}
+	return values, problems, true
+}
+
+func matchLines(segments []templateSegment, lines []string) (map[string]string, []string) {
+	// Match each Template line holding placeholders against every line of the file
+	var templateLines [][]templateSegment
+	current := []templateSegment{}
+	for _, segment := range segments {
+		if segment.Key != "" {
+			current = append(current, segment)
+			continue
+		}
+		parts := strings.Split(segment.Text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				templateLines = append(templateLines, current)
+				current = []templateSegment{}
+			}
+			current = append(current, templateSegment{Text: part, Line: segment.Line + i})
+		}
+	}
+	templateLines = append(templateLines, current)
+
+	values := make(map[string]string)
+	failed := make(map[string]bool)
+	var problems []string
+	for _, templateLine := range templateLines {
+		var keys []templateSegment
+		for _, segment := range templateLine {
+			if segment.Key != "" {
+				keys = append(keys, segment)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		var found map[string]string
+		matches := 0
+		ambiguous := false
+		for _, line := range lines {
+			lineValues, lineProblems, matched := matchSegments(templateLine, strings.TrimSuffix(line, "\r"))
+			if !matched {
+				continue
+			}
+			matches++
+			if len(lineProblems) > 0 {
+				problems = append(problems, lineProblems...)
+			}
+			if found != nil && fmt.Sprint(found) != fmt.Sprint(lineValues) {
+				ambiguous = true
+			}
+			found = lineValues
+		}
+
+		for _, segment := range keys {
+			switch {
+			case matches == 0:
+				problems = append(problems, fmt.Sprintf("line %d: %s: no matching line found", segment.Line, segment.Key))
+			case ambiguous:
+				problems = append(problems, fmt.Sprintf("line %d: %s: ambiguous, %d lines match", segment.Line, segment.Key, matches))
+			default:
+				value, ok := found[segment.Key]
+				if !ok {
+					break
+				}
+				if previous, seen := values[segment.Key]; seen && previous != value {
+					problems = append(problems, fmt.Sprintf("line %d: %s: matched different values where it is used more than once", segment.Line, segment.Key))
+				} else {
+					values[segment.Key] = value
+					continue
+				}
+			}
+			failed[segment.Key] = true
+		}
+	}
+	for key := range failed {
+		delete(values, key)
+	}
+	return values, problems
+}
+
+func segmentValue(segment templateSegment, match string) (string, error) {
+	var value string
+	var err error
+	switch segment.Quote {
+	case "":
+		return match, nil
+	case "json":
+		err = json.Unmarshal([]byte(match), &value)
+	case "toml":
+		value, err = unquoteTOML(match)
+	case "dotenv":
+		var rest string
+		value, rest, err = jaegertemplate.UnquoteDotenv(match)
+		if err == nil && rest != "" {
+			err = fmt.Errorf("trailing text after the closing quote")
+		}
+	default:
+		value, err = strconv.Unquote(match)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", match)
+	}
+	return value, nil
+}
+
+func unquoteTOML(s string) (string, error) {
+	// A TOML basic string, as rendered by {{toml .Key}}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("not a basic string")
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' || (c < 0x20 && c != '\t') || c == 0x7f {
+			return "", fmt.Errorf("unescaped character %q", c)
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("incomplete escape")
+		}
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(s[i])
+		case 'u', 'U':
+			size := 4
+			if s[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(s) {
+				return "", fmt.Errorf("incomplete escape")
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:i+1+size])
+			}
+			b.WriteRune(rune(r))
+			i += size
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
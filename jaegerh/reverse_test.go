@@ -0,0 +1,178 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholderSegments(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []templateSegment
+		wantErr  bool
+	}{
+		{"a = {{.A}}\n", []templateSegment{{Text: "a = ", Line: 1}, {Key: "A", Line: 1}, {Text: "\n", Line: 1}}, false},
+		{`{{index . "db.password"}}`, []templateSegment{{Key: "db.password", Line: 1}}, false},
+		{`{{printf "%q" .A}}`, []templateSegment{{Key: "A", Quote: "printf", Line: 1}}, false},
+		{`{{json (index . "a b")}}`, []templateSegment{{Key: "a b", Quote: "json", Line: 1}}, false},
+		{`{{toml .A}}`, []templateSegment{{Key: "A", Quote: "toml", Line: 1}}, false},
+		{`{{dotenv .A}}`, []templateSegment{{Key: "A", Quote: "dotenv", Line: 1}}, false},
+		{`{{"{{"}}`, []templateSegment{{Text: "{{", Line: 1}}, false},
+		{"x\n{{.A}}", []templateSegment{{Text: "x\n", Line: 1}, {Key: "A", Line: 2}}, false},
+		{`{{.A.B}}`, nil, true},
+		{`{{printf "%s" .A}}`, nil, true},
+		{`{{if .A}}x{{end}}`, nil, true},
+		{`{{$x := .A}}`, nil, true},
+	}
+	for _, test := range tests {
+		got, err := templateSegments(test.template)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("templateSegments(%q): expected an error, got %v", test.template, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("templateSegments(%q): unexpected error: %v", test.template, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("templateSegments(%q) = %+v, want %+v", test.template, got, test.want)
+		}
+	}
+}
+
+func TestSegmentValue(t *testing.T) {
+	tests := []struct {
+		quote   string
+		match   string
+		want    string
+		wantErr bool
+	}{
+		{"", `as "is"`, `as "is"`, false},
+		{"printf", `"a\"b\x01"`, "a\"b\x01", false},
+		{"json", `"a\"b\u0001\/"`, "a\"b\x01/", false},
+		{"toml", `"a\"b\u0001\U0001F600\t\\"`, "a\"b\x01\U0001F600\t\\", false},
+		{"toml", `"tab	literal"`, "tab\tliteral", false},
+		{"toml", `"\x01"`, "", true},
+		{"toml", `"\u12"`, "", true},
+		{"toml", `"\uD800"`, "", true},
+		{"toml", "\"nul\x00\"", "", true},
+		{"json", `"\x01"`, "", true},
+		{"printf", `"a`, "", true},
+		{"dotenv", `"it's \$x \\ \n"`, "it's $x \\ \n", false},
+		{"dotenv", `"a" b"`, "", true},
+	}
+	for _, test := range tests {
+		got, err := segmentValue(templateSegment{Key: "A", Quote: test.quote}, test.match)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("segmentValue(%s, %s): expected an error, got %q", test.quote, test.match, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("segmentValue(%s, %s): unexpected error: %v", test.quote, test.match, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("segmentValue(%s, %s) = %q, want %q", test.quote, test.match, got, test.want)
+		}
+	}
+}
+
+func TestReverseTemplate(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     string
+		rendered     string
+		want         map[string]string
+		wantProblems []string
+	}{
+		{
+			"whole file",
+			"[db]\nuser = {{.User}}\npassword = {{.Password}}\n",
+			"[db]\nuser = admin\npassword = s3cret = x\n",
+			map[string]string{"User": "admin", "Password": "s3cret = x"},
+			nil,
+		},
+		{
+			"quoted values",
+			"{\"a\": {{json .A}}, \"b\": {{printf \"%q\" .B}}}\nc = {{toml .C}}\n",
+			"{\"a\": \"x\\\"\\u0001\", \"b\": \"y\\n\"}\nc = \"z\\\\\"\n",
+			map[string]string{"A": "x\"\x01", "B": "y\n", "C": "z\\"},
+			nil,
+		},
+		{
+			"repeated placeholder",
+			"a = {{.P}}\nb = {{.P}}\n",
+			"a = x\nb = x\n",
+			map[string]string{"P": "x"},
+			nil,
+		},
+		{
+			"different values of a repeated placeholder",
+			"a = {{.P}}\nb = {{.P}}\n",
+			"a = x\nb = y\n",
+			map[string]string{},
+			[]string{"line 2: P: matched different values"},
+		},
+		{
+			"ambiguous value",
+			"{{.A}} {{.B}}\n",
+			"x y z\n",
+			map[string]string{},
+			[]string{"line 1: A: ambiguous", "line 1: B: ambiguous"},
+		},
+		{
+			"lines added outside the placeholders",
+			"user = {{.User}}\npassword = {{.Password}}\n",
+			"# added\nuser = admin\nextra = 1\npassword = s3cret\n",
+			map[string]string{"User": "admin", "Password": "s3cret"},
+			[]string{"does not match the whole Template"},
+		},
+		{
+			"line removed",
+			"user = {{.User}}\npassword = {{.Password}}\n",
+			"user = admin\n",
+			map[string]string{"User": "admin"},
+			[]string{"does not match the whole Template", "line 2: Password: no matching line found"},
+		},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		templateFile := filepath.Join(dir, "t.jgrt")
+		fromFile := filepath.Join(dir, "t")
+		if err := ioutil.WriteFile(templateFile, []byte(test.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fromFile, []byte(test.rendered), 0644); err != nil {
+			t.Fatal(err)
+		}
+		properties, problems, err := reverseTemplate(templateFile, fromFile)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		got := make(map[string]string)
+		for _, property := range properties {
+			got[property.Key] = property.Value
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: values %q, want %q", test.name, got, test.want)
+		}
+		if len(problems) != len(test.wantProblems) {
+			t.Errorf("%s: problems %q, want %q", test.name, problems, test.wantProblems)
+			continue
+		}
+		for i, problem := range problems {
+			if !strings.Contains(problem, test.wantProblems[i]) {
+				t.Errorf("%s: problem %q, want %q", test.name, problem, test.wantProblems[i])
+			}
+		}
+	}
+}
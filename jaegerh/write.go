@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegeraudit"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"github.com/jyap808/jaeger/jaegerstore"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func writeJaegerFiles(extracted extraction, outputTemplate *string, jsonGPGDB *string, entitylist openpgp.EntityList, signer *openpgp.Entity) error {
+	// Write the Template file and add the extracted values to a new or existing JSON GPG database file. Both are
+	// put back as they were when the added properties can't be recorded in the audit log.
+	start := time.Now()
+
+	if _, err := os.Stat(*outputTemplate); err == nil {
+		return fmt.Errorf("ERR: File already exists: %v", *outputTemplate)
+	}
+	storeSnapshot, err := jaegeraudit.TakeSnapshot(*jsonGPGDB)
+	if err != nil {
+		return err
+	}
+	templateSnapshot, err := jaegeraudit.TakeSnapshot(*outputTemplate)
+	if err != nil {
+		return err
+	}
+
+	added, err := addProperties(extracted.Properties, jsonGPGDB, entitylist)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*outputTemplate, []byte(extracted.Template), 0644); err != nil {
+		if restoreErr := storeSnapshot.Restore(); restoreErr != nil {
+			return fmt.Errorf("error: %v\n\nError: Unable to undo the change to %v: %v", err, *jsonGPGDB, restoreErr)
+		}
+		return fmt.Errorf("error: %v", err)
+	}
+	if err := jaegeraudit.AppendOrRestore(*jsonGPGDB, "add", added, signer, storeSnapshot, templateSnapshot); err != nil {
+		return err
+	}
+
+	jaegerlog.Info("wrote template and store", jaegerlog.Operation("write"), jaegerlog.Template(*outputTemplate), jaegerlog.Store(*jsonGPGDB),
+		"properties", len(added), jaegerlog.Duration(start))
+	return nil
+}
+
+func addProperties(properties []extractedProperty, jsonGPGDB *string, entitylist openpgp.EntityList) ([]string, error) {
+	// Encrypt the properties into a new or existing JSON GPG database file and return the names added. Nothing
+	// is written if any property already exists.
+	var j jaegerstore.Data
+	if jsonGPGDBBuffer, err := ioutil.ReadFile(*jsonGPGDB); err == nil {
+		if err := json.Unmarshal(jsonGPGDBBuffer, &j); err != nil {
+			return nil, fmt.Errorf("error: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("ERROR: Unable to read JSON GPG DB file")
+	}
+
+	existing := make(map[string]bool)
+	for _, property := range j.Properties {
+		existing[property.Name] = true
+	}
+
+	// Check everything before writing anything
+	values := make(map[string]string)
+	var conflicts []string
+	for _, property := range properties {
+		if value, found := values[property.Key]; found {
+			if value != property.Value {
+				return nil, fmt.Errorf("\n\nError: Property '%s' appears more than once with different values", property.Key)
+			}
+			continue
+		}
+		values[property.Key] = property.Value
+		if existing[property.Key] {
+			conflicts = append(conflicts, property.Key)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("\n\nError: Properties already exist in %v: %s", *jsonGPGDB, strings.Join(conflicts, ", "))
+	}
+
+	var added []string
+	for _, property := range properties {
+		if existing[property.Key] {
+			continue
+		}
+		existing[property.Key] = true
+		added = append(added, property.Key)
+		encryptedValue, err := jaegerstore.Encrypt(property.Value, entitylist)
+		if err != nil {
+			return nil, err
+		}
+		p := jaegerstore.Property{Name: property.Key}
+		p.SetValue(encryptedValue, 0)
+		j.Properties = append(j.Properties, p)
+	}
+
+	bytes, err := jaegerstore.Marshal(j)
+	if err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	if err := ioutil.WriteFile(*jsonGPGDB, bytes, 0644); err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	return added, nil
+}
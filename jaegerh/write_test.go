@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto"
+	"github.com/jyap808/jaeger/jaegeraudit"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteJaegerFiles(t *testing.T) {
+	entity, err := openpgp.NewEntity("Jaeger Test", "", "jaeger@example.com", &packet.Config{RSABits: 1024, DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entitylist := openpgp.EntityList{entity}
+	extracted := extraction{
+		Template:   "password = {{.Password}}\ntoken = {{.Token}}\n",
+		Properties: []extractedProperty{{RawKey: "password", Key: "Password", Value: "hunter2"}, {RawKey: "token", Key: "Token", Value: "t1"}},
+	}
+
+	tests := []struct {
+		name        string
+		auditFailed bool
+	}{
+		{"audit log written", false},
+		{"audit log can't be written", true},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		outputTemplate := filepath.Join(dir, "app.conf.jgrt")
+		jsonGPGDB := filepath.Join(dir, "app.conf.jgrdb")
+		if test.auditFailed {
+			if err := os.Mkdir(jaegeraudit.Path(jsonGPGDB), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := writeJaegerFiles(extracted, &outputTemplate, &jsonGPGDB, entitylist, entity)
+		if test.auditFailed {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			for _, path := range []string{outputTemplate, jsonGPGDB} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s: %s was kept, want it removed", test.name, path)
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if content, _ := ioutil.ReadFile(outputTemplate); string(content) != extracted.Template {
+			t.Errorf("%s: Template = %q, want %q", test.name, content, extracted.Template)
+		}
+		summary, err := jaegeraudit.Verify(jsonGPGDB, entitylist, true, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Entries != 2 || len(summary.Problems) != 0 {
+			t.Errorf("%s: audit log has %d entries and problems %q, want 2 signed entries", test.name, summary.Entries, summary.Problems)
+		}
+	}
+}
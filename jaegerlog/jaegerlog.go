@@ -0,0 +1,170 @@
+// Package jaegerlog is the leveled, structured logger shared by jaeger, jaegerdb and jaegerh.
+//
+// Log records go to stderr as text or JSON. Messages written with the standard log package, eg. by log.Fatal, are
+// routed through the same handler at error level so a log pipeline sees one format.
+package jaegerlog
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Field names used consistently by all commands
+const (
+	FieldStore     = "store"
+	FieldTemplate  = "template"
+	FieldOutput    = "output"
+	FieldProperty  = "property"
+	FieldOperation = "operation"
+	FieldDuration  = "duration"
+)
+
+// Unsafe allows decrypted values and passphrases to appear in log output
+var Unsafe = false
+
+// Flags holds the logging command line flags common to all commands
+type Flags struct {
+	Debug       *bool
+	DebugUnsafe *bool
+	Level       *string
+	Format      *string
+}
+
+// RegisterFlags defines the logging flags on the default flag set
+func RegisterFlags() Flags {
+	return Flags{
+		Debug:       flag.Bool("d", false, "Enable Debug. Same as -log-level debug"),
+		DebugUnsafe: flag.Bool("d-unsafe", false, "Enable Debug including decrypted values and passphrases. Do not use where logs are kept"),
+		Level:       flag.String("log-level", "warn", "Log level. One of: debug, info, warn, error"),
+		Format:      flag.String("log-format", "text", "Log format. One of: text, json"),
+	}
+}
+
+// Setup configures the default logger from the parsed flags
+func (f Flags) Setup() error {
+	level := *f.Level
+	if *f.Debug || *f.DebugUnsafe {
+		level = "debug"
+	}
+	Unsafe = *f.DebugUnsafe
+	return Setup(level, *f.Format)
+}
+
+// Setup configures the default logger with the given level and format
+func Setup(level string, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("ERROR: Unknown log level: %v", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("ERROR: Unknown log format: %v", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	slog.SetLogLoggerLevel(slog.LevelError)
+	log.SetFlags(0)
+	return nil
+}
+
+// Debug logs at debug level
+func Debug(msg string, args ...interface{}) {
+	slog.Debug(msg, args...)
+}
+
+// Info logs at info level
+func Info(msg string, args ...interface{}) {
+	slog.Info(msg, args...)
+}
+
+// Warn logs at warn level
+func Warn(msg string, args ...interface{}) {
+	slog.Warn(msg, args...)
+}
+
+// Error logs at error level
+func Error(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+}
+
+// Store is the field for a JSON GPG database file path
+func Store(path string) slog.Attr {
+	return slog.String(FieldStore, path)
+}
+
+// Template is the field for a Template file path
+func Template(path string) slog.Attr {
+	return slog.String(FieldTemplate, path)
+}
+
+// Output is the field for a generated Output file path
+func Output(path string) slog.Attr {
+	return slog.String(FieldOutput, path)
+}
+
+// Property is the field for a property name
+func Property(name string) slog.Attr {
+	return slog.String(FieldProperty, name)
+}
+
+// Operation is the field for the operation being performed, eg. add, change, render
+func Operation(op string) slog.Attr {
+	return slog.String(FieldOperation, op)
+}
+
+// Duration is the field for the time elapsed since start
+func Duration(start time.Time) slog.Attr {
+	return slog.Duration(FieldDuration, time.Since(start))
+}
+
+// Secret is a field whose value is redacted unless Unsafe is set
+func Secret(key string, value string) slog.Attr {
+	return slog.Any(key, secret(value))
+}
+
+type secret string
+
+func (s secret) LogValue() slog.Value {
+	return slog.StringValue(Redact(string(s)))
+}
+
+// Passphrase is a field that only shows whether a passphrase is set, unless Unsafe is set
+func Passphrase(key string, value string) slog.Attr {
+	if Unsafe {
+		return slog.String(key, value)
+	}
+	if value == "" {
+		return slog.String(key, "<not set>")
+	}
+	return slog.String(key, "<set>")
+}
+
+// Redact describes a secret by its size only. A hash, even a short one, could be checked against guessed values
+// by anyone reading the log.
+func Redact(s string) string {
+	if Unsafe {
+		return s
+	}
+	return fmt.Sprintf("<redacted %d bytes>", len(s))
+}
+
+// RedactProperties keeps property names and redacts their values
+func RedactProperties(p map[string]string) map[string]string {
+	r := make(map[string]string, len(p))
+	for k, v := range p {
+		r[k] = Redact(v)
+	}
+	return r
+}
@@ -0,0 +1,108 @@
+package jaegerlog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	// Send log records to a buffer as text for the rest of the test
+	t.Helper()
+	var buf bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		slog.SetDefault(saved)
+		Unsafe = false
+	})
+	return &buf
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		value  string
+		unsafe bool
+		want   string
+	}{
+		{"s3cret", false, "<redacted 6 bytes>"},
+		{"", false, "<redacted 0 bytes>"},
+		{"s3cret", true, "s3cret"},
+	}
+	for _, test := range tests {
+		Unsafe = test.unsafe
+		if got := Redact(test.value); got != test.want {
+			t.Errorf("Redact(%q) with Unsafe %v = %q, want %q", test.value, test.unsafe, got, test.want)
+		}
+	}
+	Unsafe = false
+}
+
+func TestSecretAndPassphrase(t *testing.T) {
+	tests := []struct {
+		name    string
+		attr    func() slog.Attr
+		unsafe  bool
+		want    string
+		notWant string
+	}{
+		{"secret", func() slog.Attr { return Secret("value", "s3cret") }, false, `value="<redacted 6 bytes>"`, "s3cret"},
+		{"unsafe secret", func() slog.Attr { return Secret("value", "s3cret") }, true, "value=s3cret", "redacted"},
+		{"passphrase", func() slog.Attr { return Passphrase("passphrase", "hunter2") }, false, "passphrase=<set>", "hunter2"},
+		{"no passphrase", func() slog.Attr { return Passphrase("passphrase", "") }, false, "passphrase=\"<not set>\"", ""},
+		{"unsafe passphrase", func() slog.Attr { return Passphrase("passphrase", "hunter2") }, true, "passphrase=hunter2", ""},
+	}
+	for _, test := range tests {
+		buf := captureLog(t)
+		Unsafe = test.unsafe
+		Debug("test", test.attr())
+		got := buf.String()
+		if !strings.Contains(got, test.want) {
+			t.Errorf("%s: logged %q, want %q", test.name, got, test.want)
+		}
+		if test.notWant != "" && strings.Contains(got, test.notWant) {
+			t.Errorf("%s: logged %q, which holds %q", test.name, got, test.notWant)
+		}
+	}
+}
+
+func TestRedactProperties(t *testing.T) {
+	got := RedactProperties(map[string]string{"A": "s3cret", "B": ""})
+	if got["A"] != "<redacted 6 bytes>" || got["B"] != "<redacted 0 bytes>" || len(got) != 2 {
+		t.Errorf("RedactProperties() = %q", got)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	saved := slog.Default()
+	defer slog.SetDefault(saved)
+
+	tests := []struct {
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{"debug", "text", false},
+		{"warn", "json", false},
+		{"ERROR", "JSON", false},
+		{"verbose", "text", true},
+		{"info", "xml", true},
+	}
+	for _, test := range tests {
+		err := Setup(test.level, test.format)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Setup(%q, %q) error = %v, want error %v", test.level, test.format, err, test.wantErr)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	buf := captureLog(t)
+	Info("test", Store("a.jgrdb"), Template("a.jgrt"), Output("a"), Property("P"), Operation("render"))
+	for _, want := range []string{"store=a.jgrdb", "template=a.jgrt", "output=a", "property=P", "operation=render"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("logged %q, want %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Package jaegerstore is the JSON GPG database file format and the OpenPGP helpers shared by jaeger, jaegerdb and
+// jaegerh.
+//
+// A store holds one encrypted value per property. Each value is encrypted to the public key and base64 encoded, so
+// the file can be kept in version control and only the holder of the secret key can read the values.
+package jaegerstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jyap808/jaeger/jaegerlog"
+	"golang.org/x/crypto/openpgp"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Data is the content of a JSON GPG database file
+type Data struct {
+	Properties []Property
+}
+
+// Property is a named encrypted value together with the previous values kept by jaegerdb
+type Property struct {
+	Name           string            `json:"Name"`
+	EncryptedValue string            `json:"EncryptedValue"`
+	Version        int               `json:"Version,omitempty"`
+	Updated        string            `json:"Updated,omitempty"`
+	History        []PropertyVersion `json:"History,omitempty"`
+}
+
+// PropertyVersion is a previous value of a property. Versions are numbered from 1 and never reused, so a
+// version keeps its number when older versions are dropped.
+type PropertyVersion struct {
+	Version        int    `json:"Version"`
+	EncryptedValue string `json:"EncryptedValue"`
+	Updated        string `json:"Updated,omitempty"`
+}
+
+// ErrNoSecretKeyRing is returned by SecretKeyRing when no keyring file is given and no default keyring exists
+var ErrNoSecretKeyRing = errors.New("no secret keyring found")
+
+// ErrNoSigningKey is returned by SigningKey when no key file is given and no default keyring exists
+var ErrNoSigningKey = errors.New("no signing key found")
+
+// CurrentVersion returns the version of the current value. Properties written before versions were kept are
+// version 1.
+func (p Property) CurrentVersion() int {
+	if p.Version < 1 {
+		return 1
+	}
+	return p.Version
+}
+
+// SetValue replaces the value of a property, keeping the value it had as a previous version. Only the last
+// historySize previous versions are kept.
+func (p *Property) SetValue(encryptedValue string, historySize int) {
+	version := 1
+	if p.EncryptedValue != "" {
+		p.History = append(p.History, PropertyVersion{
+			Version:        p.CurrentVersion(),
+			EncryptedValue: p.EncryptedValue,
+			Updated:        p.Updated,
+		})
+		version = p.CurrentVersion() + 1
+	}
+	if historySize < 0 {
+		historySize = 0
+	}
+	if len(p.History) > historySize {
+		p.History = append([]PropertyVersion(nil), p.History[len(p.History)-historySize:]...)
+	}
+	if len(p.History) == 0 {
+		p.History = nil
+	}
+	p.EncryptedValue = encryptedValue
+	p.Version = version
+	p.Updated = time.Now().UTC().Format(time.RFC3339)
+}
+
+// Find returns the named property, or nil if the store does not hold it
+func (j *Data) Find(name string) *Property {
+	for i := range j.Properties {
+		if j.Properties[i].Name == name {
+			return &j.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Marshal returns the canonical form of a JSON GPG database file: properties sorted by name, four space
+// indentation and a trailing newline, so the file only changes where a property changes
+func Marshal(j Data) ([]byte, error) {
+	properties := make([]Property, len(j.Properties))
+	copy(properties, j.Properties)
+	sort.SliceStable(properties, func(a, b int) bool {
+		return properties[a].Name < properties[b].Name
+	})
+	j.Properties = properties
+
+	bytes, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes, '\n'), nil
+}
+
+// Encrypt encrypts a value to the public keys in entitylist and encodes it with base64
+func Encrypt(s string, entitylist openpgp.EntityList) (string, error) {
+	jaegerlog.Debug("encrypting message", jaegerlog.Secret("value", s))
+	buf := new(bytes.Buffer)
+	w, err := openpgp.Encrypt(buf, entitylist, nil, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("ERR: Error encrypting message - %v", err)
+	}
+	if _, err := w.Write([]byte(s)); err != nil {
+		return "", fmt.Errorf("ERR: Error encrypting message - %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("ERR: Error encrypting message - %v", err)
+	}
+
+	str := base64.StdEncoding.EncodeToString(buf.Bytes())
+	jaegerlog.Debug("public key encrypted message (base64 encoded)", "encrypted", str)
+	return str, nil
+}
+
+// Decrypt decodes a base64 encoded encrypted value and decrypts it with a decrypted private key from keyring
+func Decrypt(s string, keyring openpgp.KeyRing) (string, error) {
+	dec, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("ERR: %v", err)
+	}
+	md, err := openpgp.ReadMessage(bytes.NewBuffer(dec), keyring, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("ERR: Error reading message - %v", err)
+	}
+
+	bytes, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", fmt.Errorf("ERR: Error reading message - %v", err)
+	}
+	jaegerlog.Debug("decrypted message", jaegerlog.Secret("value", string(bytes)))
+	return string(bytes), nil
+}
+
+// PublicKeyRing reads an ASCII armored public key, or with an empty keyringFile the default public keyring:
+// ~/.gnupg/jaeger_pubring.gpg if it exists, otherwise ~/.gnupg/pubring.gpg
+func PublicKeyRing(keyringFile string) (openpgp.EntityList, error) {
+	path := keyringFile
+	if path == "" {
+		path = defaultKeyRing("jaeger_pubring.gpg", "pubring.gpg")
+		if path == "" {
+			return nil, fmt.Errorf("ERROR: No public keyring found. Use -k to specify an ASCII armored public key.")
+		}
+	}
+	entitylist, err := readKeyRing(path, keyringFile != "")
+	if err != nil {
+		return nil, err
+	}
+	jaegerlog.Debug("public key", "keyring", path, "identities", IdentityNames(entitylist[0]))
+	return entitylist, nil
+}
+
+// SecretKeyRing reads an ASCII armored private key, or with an empty keyringFile the default secret keyring:
+// ~/.gnupg/jaeger_secring.gpg if it exists, otherwise ~/.gnupg/secring.gpg. The private key and its subkeys are
+// decrypted with passphrase. ErrNoSecretKeyRing is returned when there is no default secret keyring.
+func SecretKeyRing(keyringFile string, passphrase string) (openpgp.EntityList, error) {
+	path := keyringFile
+	if path == "" {
+		path = defaultKeyRing("jaeger_secring.gpg", "secring.gpg")
+		if path == "" {
+			jaegerlog.Debug("no secret keyring found")
+			return nil, ErrNoSecretKeyRing
+		}
+	}
+	entitylist, err := readKeyRing(path, keyringFile != "")
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptPrivateKey(entitylist[0], path, passphrase); err != nil {
+		return nil, err
+	}
+	return entitylist, nil
+}
+
+// SigningKey reads the personal private key that signs audit log entries: an ASCII armored private key, or with
+// an empty keyFile ~/.gnupg/secring.gpg. It is deliberately not the secret key of the store, which everyone who
+// can decrypt the store shares, so a signature names the person who made the change. ErrNoSigningKey is returned
+// when there is no default keyring.
+func SigningKey(keyFile string, passphrase string) (*openpgp.Entity, error) {
+	path := keyFile
+	if path == "" {
+		path = defaultKeyRing("secring.gpg")
+		if path == "" {
+			jaegerlog.Debug("no signing key found")
+			return nil, ErrNoSigningKey
+		}
+	}
+	entitylist, err := readKeyRing(path, keyFile != "")
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptPrivateKey(entitylist[0], path, passphrase); err != nil {
+		return nil, err
+	}
+	return entitylist[0], nil
+}
+
+func decryptPrivateKey(entity *openpgp.Entity, path string, passphrase string) error {
+	if entity.PrivateKey == nil {
+		return fmt.Errorf("ERROR: %v does not hold a private key", path)
+	}
+	if entity.PrivateKey.Encrypted {
+		jaegerlog.Debug("decrypting private key using passphrase")
+		if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return fmt.Errorf("ERROR: Failed to decrypt key using passphrase. Make sure you specify a passphrase if required.")
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return fmt.Errorf("ERROR: Failed to decrypt subkey")
+			}
+		}
+	}
+	jaegerlog.Debug("private key", "keyring", path, "identities", IdentityNames(entity))
+	return nil
+}
+
+// IdentityNames returns the user IDs of a key, eg. "Jaeger <jaeger@example.com>"
+func IdentityNames(entity *openpgp.Entity) []string {
+	var names []string
+	for name := range entity.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func defaultKeyRing(names ...string) string {
+	// The first of the named keyrings in ~/.gnupg that exists
+	usr, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	for _, name := range names {
+		path := filepath.Join(usr.HomeDir, ".gnupg", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func readKeyRing(path string, armored bool) (openpgp.EntityList, error) {
+	jaegerlog.Debug("reading keyring", "keyring", path)
+	keyRingBuffer, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Unable to read keyring file: %v", err)
+	}
+	defer keyRingBuffer.Close()
+
+	var entitylist openpgp.EntityList
+	if armored {
+		entitylist, err = openpgp.ReadArmoredKeyRing(keyRingBuffer)
+	} else {
+		entitylist, err = openpgp.ReadKeyRing(keyRingBuffer)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: %v: %v", path, err)
+	}
+	if len(entitylist) == 0 {
+		return nil, fmt.Errorf("ERROR: %v does not hold a key", path)
+	}
+	return entitylist, nil
+}
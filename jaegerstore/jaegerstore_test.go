@@ -0,0 +1,149 @@
+package jaegerstore
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	j := Data{Properties: []Property{{Name: "B", EncryptedValue: "b"}, {Name: "A", EncryptedValue: "a", Version: 2}}}
+	got, err := Marshal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+    "Properties": [
+        {
+            "Name": "A",
+            "EncryptedValue": "a",
+            "Version": 2
+        },
+        {
+            "Name": "B",
+            "EncryptedValue": "b"
+        }
+    ]
+}
+`
+	if string(got) != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+	if j.Properties[0].Name != "B" {
+		t.Errorf("Marshal() reordered the properties of its argument")
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		property    Property
+		historySize int
+		wantVersion int
+		wantHistory []int
+	}{
+		{"new property", Property{Name: "A"}, 5, 1, nil},
+		{"property without a version", Property{Name: "A", EncryptedValue: "v1"}, 5, 2, []int{1}},
+		{"history kept", Property{Name: "A", EncryptedValue: "v3", Version: 3, History: []PropertyVersion{{Version: 1}, {Version: 2}}}, 5, 4, []int{1, 2, 3}},
+		{"history trimmed", Property{Name: "A", EncryptedValue: "v3", Version: 3, History: []PropertyVersion{{Version: 1}, {Version: 2}}}, 2, 4, []int{2, 3}},
+		{"no history", Property{Name: "A", EncryptedValue: "v3", Version: 3, History: []PropertyVersion{{Version: 2}}}, 0, 4, nil},
+	}
+	for _, test := range tests {
+		p := test.property
+		p.SetValue("new", test.historySize)
+		var history []int
+		for _, v := range p.History {
+			history = append(history, v.Version)
+		}
+		if p.EncryptedValue != "new" || p.Version != test.wantVersion || p.Updated == "" {
+			t.Errorf("%s: got %q version %d updated %q, want %q version %d", test.name, p.EncryptedValue, p.Version, p.Updated, "new", test.wantVersion)
+		}
+		if !reflect.DeepEqual(history, test.wantHistory) {
+			t.Errorf("%s: history versions %v, want %v", test.name, history, test.wantHistory)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	j := Data{Properties: []Property{{Name: "A"}, {Name: "B"}}}
+	if p := j.Find("B"); p == nil || p != &j.Properties[1] {
+		t.Errorf("Find(%q) = %v, want the stored property", "B", p)
+	}
+	if p := j.Find("C"); p != nil {
+		t.Errorf("Find(%q) = %v, want nil", "C", p)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	entity, err := openpgp.NewEntity("Jaeger Test", "", "jaeger@example.com", &packet.Config{RSABits: 1024, DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entitylist := openpgp.EntityList{entity}
+	for _, value := range []string{"", "secret", "line\nbreak \x00 é"} {
+		encrypted, err := Encrypt(value, entitylist)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decrypt(encrypted, entitylist)
+		if err != nil {
+			t.Fatalf("Decrypt(Encrypt(%q)): %v", value, err)
+		}
+		if got != value {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", value, got)
+		}
+	}
+	if _, err := Decrypt("not base64!", entitylist); err == nil {
+		t.Errorf("Decrypt of invalid base64: expected an error")
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("Jaeger Test", "", "jaeger@example.com", &packet.Config{RSABits: 1024, DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeArmoredKey := func(name string, blockType string, serialize func(w io.Writer) error) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		w, err := armor.Encode(f, blockType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := serialize(w); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	privateKey := writeArmoredKey("private.asc", openpgp.PrivateKeyType, func(w io.Writer) error { return entity.SerializePrivate(w, nil) })
+	publicKey := writeArmoredKey("public.asc", openpgp.PublicKeyType, entity.Serialize)
+
+	signer, err := SigningKey(privateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.PrimaryKey.KeyId != entity.PrimaryKey.KeyId || signer.PrivateKey == nil {
+		t.Errorf("SigningKey(%s) = key %X, want the private key %X", privateKey, signer.PrimaryKey.KeyId, entity.PrimaryKey.KeyId)
+	}
+	for _, keyFile := range []string{publicKey, filepath.Join(dir, "missing.asc")} {
+		if _, err := SigningKey(keyFile, ""); err == nil || err == ErrNoSigningKey {
+			t.Errorf("SigningKey(%s): expected an error, got %v", keyFile, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package jaegertemplate holds the Template functions shared by jaeger, jaegerdb and jaegerh, and the walk over
+// the properties a Template refers to.
+//
+// Every Template is parsed with FuncMap, so a Template written by jaegerh renders with jaeger and can be checked
+// or rewritten by jaegerdb. Both find the references with WalkReferences.
+package jaegertemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// FuncMap holds the functions available to a Template in addition to the text/template builtins:
+//
+//	{{json .Key}}    renders the value as a JSON string, including the double quotes
+//	{{toml .Key}}    renders the value as a TOML basic string, including the double quotes
+//	{{dotenv .Key}}  renders the value as a double quoted dotenv value, including the double quotes
+var FuncMap = template.FuncMap{
+	"json":   JSONString,
+	"toml":   TOMLString,
+	"dotenv": DotenvString,
+}
+
+// JSONString returns s as a double quoted JSON string. HTML characters are kept as they are.
+func JSONString(s string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// TOMLString returns s as a double quoted TOML basic string. Control characters, which TOML does not allow
+// in a basic string, are escaped.
+func TOMLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// DotenvString returns s as a double quoted dotenv value. Loaders and shells expand double quoted values, so $, `
+// and \ are escaped along with the quote. Line breaks are written as \n and \r. Other control characters have no
+// escape that loaders agree on and are an error.
+func DotenvString(s string) (string, error) {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\', '"', '$', '`':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if unicode.IsControl(r) && r != '\t' {
+				return "", fmt.Errorf("control character %U can't be written as dotenv", r)
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+// UnquoteDotenv reads the double quoted dotenv value at the start of s, as written by DotenvString, and returns
+// it with the rest of s after the closing quote. \n, \r and \t are line breaks and tabs, a backslash before any
+// other character is kept as the shell keeps it.
+func UnquoteDotenv(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("invalid double quoted value")
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", fmt.Errorf("invalid double quoted value")
+			}
+			i++
+			switch s[i] {
+			case '\\', '"', '$', '`':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("invalid double quoted value")
+}
@@ -0,0 +1,132 @@
+package jaegertemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"text/template"
+)
+
+func TestJSONString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", `"plain"`},
+		{`quote " and \ backslash`, `"quote \" and \\ backslash"`},
+		{"line\nbreak\ttab", `"line\nbreak\ttab"`},
+		{"nul\x00 escape\x1b del\x7f", `"nul\u0000 escape\u001b del` + "\x7f" + `"`},
+		{"<html> & é", `"<html> & é"`},
+	}
+	for _, test := range tests {
+		got, err := JSONString(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("JSONString(%q) = %s, want %s", test.value, got, test.want)
+		}
+		var decoded string
+		if err := json.Unmarshal([]byte(got), &decoded); err != nil || decoded != test.value {
+			t.Errorf("JSONString(%q) = %s decodes to %q, %v", test.value, got, decoded, err)
+		}
+	}
+}
+
+func TestTOMLString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", `"plain"`},
+		{`quote " and \ backslash`, `"quote \" and \\ backslash"`},
+		{"line\nbreak\ttab\r\b\f", `"line\nbreak\ttab\r\b\f"`},
+		{"nul\x00 escape\x1b del\x7f", `"nul\u0000 escape\u001B del\u007F"`},
+		{"é ☃", `"é ☃"`},
+	}
+	for _, test := range tests {
+		if got := TOMLString(test.value); got != test.want {
+			t.Errorf("TOMLString(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestDotenvString(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", `"plain"`, false},
+		{"", `""`, false},
+		{"it's $HOME `cmd` \\ \"q\"", "\"it's \\$HOME \\`cmd\\` \\\\ \\\"q\\\"\"", false},
+		{"line\nbreak\r\ttab", "\"line\\nbreak\\r\ttab\"", false},
+		{"é ☃", `"é ☃"`, false},
+		{"nul\x00", "", true},
+		{"escape\x1b", "", true},
+	}
+	for _, test := range tests {
+		got, err := DotenvString(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("DotenvString(%q): expected an error, got %s", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DotenvString(%q): unexpected error: %v", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("DotenvString(%q) = %s, want %s", test.value, got, test.want)
+		}
+		if decoded, rest, err := UnquoteDotenv(got + " # comment"); err != nil || decoded != test.value || rest != " # comment" {
+			t.Errorf("UnquoteDotenv(%s) = %q, %q, %v, want %q", got, decoded, rest, err, test.value)
+		}
+	}
+}
+
+func TestUnquoteDotenv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		rest    string
+		wantErr bool
+	}{
+		{`"a\"b" rest`, `a"b`, " rest", false},
+		{`"\$x \` + "`" + ` \\ \n\r\t"`, "$x ` \\ \n\r\t", "", false},
+		{`"kept \d"`, `kept \d`, "", false},
+		{`"tab	literal"`, "tab\tliteral", "", false},
+		{`"unterminated`, "", "", true},
+		{`"trailing \`, "", "", true},
+		{`'single'`, "", "", true},
+	}
+	for _, test := range tests {
+		got, rest, err := UnquoteDotenv(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("UnquoteDotenv(%s): expected an error, got %q", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UnquoteDotenv(%s): unexpected error: %v", test.value, err)
+			continue
+		}
+		if got != test.want || rest != test.rest {
+			t.Errorf("UnquoteDotenv(%s) = %q, %q, want %q, %q", test.value, got, rest, test.want, test.rest)
+		}
+	}
+}
+
+func TestFuncMap(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(FuncMap).Parse(`{"a": {{json .A}}}` + "\n" + `b = {{toml (index . "B")}}` + "\n" + `C={{dotenv .C}}`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]string{"A": "x\x01y", "B": "x\x01y", "C": "$x\ny"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a": "x\u0001y"}` + "\n" + `b = "x\u0001y"` + "\n" + `C="\$x\ny"`
+	if buf.String() != want {
+		t.Errorf("rendered %q, want %q", buf.String(), want)
+	}
+}
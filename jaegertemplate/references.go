@@ -0,0 +1,74 @@
+package jaegertemplate
+
+import (
+	"text/template/parse"
+)
+
+// WalkReferences calls add for each property a parsed Template refers to: {{.Name}}, {{$.Name}} and
+// {{index . "Name"}}. Dot is the map of properties outside range and with, inside them only $ refers to the
+// properties. add gets the node holding the name, a FieldNode, a VariableNode or the StringNode of index, so
+// the reference can be located or rewritten.
+func WalkReferences(root *parse.ListNode, add func(name string, node parse.Node)) {
+	walkReferences(root, true, add)
+}
+
+func walkReferences(node parse.Node, topLevel bool, add func(string, parse.Node)) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			walkReferences(n, topLevel, add)
+		}
+	case *parse.ActionNode:
+		walkReferences(node.Pipe, topLevel, add)
+	case *parse.IfNode:
+		walkReferences(node.Pipe, topLevel, add)
+		walkReferences(node.List, topLevel, add)
+		walkReferences(node.ElseList, topLevel, add)
+	case *parse.RangeNode:
+		walkReferences(node.Pipe, topLevel, add)
+		walkReferences(node.List, false, add)
+		walkReferences(node.ElseList, topLevel, add)
+	case *parse.WithNode:
+		walkReferences(node.Pipe, topLevel, add)
+		walkReferences(node.List, false, add)
+		walkReferences(node.ElseList, topLevel, add)
+	case *parse.TemplateNode:
+		walkReferences(node.Pipe, topLevel, add)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, cmd := range node.Cmds {
+			walkReferences(cmd, topLevel, add)
+		}
+	case *parse.CommandNode:
+		// index . "Name" or index $ "Name"
+		if len(node.Args) == 3 {
+			if ident, ok := node.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "index" {
+				_, isDot := node.Args[1].(*parse.DotNode)
+				variable, isVariable := node.Args[1].(*parse.VariableNode)
+				key, isString := node.Args[2].(*parse.StringNode)
+				if isString && ((isDot && topLevel) || (isVariable && len(variable.Ident) == 1 && variable.Ident[0] == "$")) {
+					add(key.Text, key)
+					return
+				}
+			}
+		}
+		for _, arg := range node.Args {
+			walkReferences(arg, topLevel, add)
+		}
+	case *parse.FieldNode:
+		if topLevel {
+			add(node.Ident[0], node)
+		}
+	case *parse.VariableNode:
+		if len(node.Ident) > 1 && node.Ident[0] == "$" {
+			add(node.Ident[1], node)
+		}
+	case *parse.ChainNode:
+		walkReferences(node.Node, topLevel, add)
+	}
+}
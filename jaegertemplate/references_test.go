@@ -0,0 +1,36 @@
+package jaegertemplate
+
+import (
+	"reflect"
+	"testing"
+	"text/template"
+	"text/template/parse"
+)
+
+func TestWalkReferences(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{"{{.A}} {{$.B}} {{index . \"c d\"}} {{index $ \"E\"}}", []string{"A", "B", "c d", "E"}},
+		{"{{json .A}} {{toml (index . \"B\")}}", []string{"A", "B"}},
+		{"{{if .A}}{{.B}}{{else}}{{.C}}{{end}}", []string{"A", "B", "C"}},
+		{"{{range .A}}{{.Inner}}{{$.B}}{{index . \"x\"}}{{end}}", []string{"A", "B"}},
+		{"{{with .A}}{{.Inner}}{{else}}{{.B}}{{end}}", []string{"A", "B"}},
+		{"{{.A.Inner}} {{$x := .B}}{{$x.Inner}}", []string{"A", "B"}},
+		{"plain text", nil},
+	}
+	for _, test := range tests {
+		tmpl, err := template.New("t").Funcs(FuncMap).Parse(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		WalkReferences(tmpl.Tree.Root, func(name string, node parse.Node) {
+			got = append(got, name)
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WalkReferences(%q) = %q, want %q", test.template, got, test.want)
+		}
+	}
+}